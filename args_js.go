@@ -0,0 +1,31 @@
+//go:build js
+
+package main
+
+import (
+	"flag"
+	"net/url"
+	"strings"
+	"syscall/js"
+)
+
+// args returns the query parameters of the page as command line arguments,
+// so that e.g. ?seed=42 works like -seed=42 on native builds.
+// Unknown parameters are ignored.
+func args() []string {
+	search := js.Global().Get("location").Get("search").String()
+	query, err := url.ParseQuery(strings.TrimPrefix(search, "?"))
+	if err != nil {
+		return nil
+	}
+	var args []string
+	for name, values := range query {
+		if flag.Lookup(name) == nil {
+			continue
+		}
+		for _, v := range values {
+			args = append(args, "-"+name+"="+v)
+		}
+	}
+	return args
+}
@@ -0,0 +1,10 @@
+//go:build !js
+
+package main
+
+import "os"
+
+// args returns the command line arguments.
+func args() []string {
+	return os.Args[1:]
+}
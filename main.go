@@ -1,14 +1,51 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"math/rand/v2"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"sisyphos.optimisticotter.me/sisyphos"
+	"sisyphos.optimisticotter.me/sisyphos/replay"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
 )
 
+// random seeds are kept short so that they are easy to share
+const maxRandomSeed = 1_000_000
+
 func main() {
-	game, err := sisyphos.NewGame()
+	seed := flag.Uint64("seed", rand.Uint64N(maxRandomSeed), "seed of the generated boards")
+	replayFlag := flag.String("replay", "", "replay to play back, as logged when a board is completed")
+	solution := flag.String("solution", "", "solution of the first board in the LURD notation to verify and play back")
+	if err := flag.CommandLine.Parse(args()); err != nil {
+		log.Fatal(err)
+	}
+	// The saved progress is resumed unless a seed is given.
+	resume := true
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			resume = false
+		}
+	})
+	var rep *replay.Replay
+	if *replayFlag != "" {
+		var err error
+		if rep, err = replay.Parse(*replayFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+	store, err := storage.Default()
+	if err != nil {
+		log.Println("progress is not persisted:", err)
+	}
+	game, err := sisyphos.NewGame(sisyphos.Config{
+		Seed:     *seed,
+		Store:    store,
+		Resume:   resume,
+		Replay:   rep,
+		Solution: *solution,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
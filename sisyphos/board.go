@@ -2,9 +2,12 @@ package sisyphos
 
 import (
 	"errors"
-	"log"
+	"slices"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"sisyphos.optimisticotter.me/sisyphos/deadlock"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
 )
 
 var taskTerminated = errors.New("sisyphos: task terminated")
@@ -12,36 +15,130 @@ var taskTerminated = errors.New("sisyphos: task terminated")
 type task func() error
 
 // Board represents the game board.
+//
+// The rules are tracked by state; tiles only animate the transitions between states.
 type Board struct {
-	size  int
-	tiles map[*Tile]struct{}
-	tasks []task
+	state rules.State
+	// history holds the initial state and every move made since.
+	// Undone moves stay in history after current until a new move is made.
+	history []step
+	current int
+	// outside marks the floor cells outside of the walls, which are not drawn.
+	outside []bool
+	tiles   map[*Tile]struct{}
+	tasks   []task
+
+	moves  int
+	pushes int
+	// ticks counts the updates while the board is not won yet.
+	ticks int
+	// hints counts the hints that suggested a move.
+	hints int
+	// hint is shown until the next move.
+	hint *hint
+
+	deadlocks *deadlock.Detector
+	// stuck is the boulder that cannot reach a target any more, or the target
+	// that cannot be covered any more, or nil.
+	stuck *rules.Pos
+}
+
+// step represents a state in the board history.
+type step struct {
+	state rules.State
+	// result is the move that led to state.
+	result rules.MoveResult
+	pushes int
 }
 
-// NewBoard generates a new Board with giving a size.
-func NewBoard(size int, blocks int) (*Board, error) {
-	log.Println("creating board of size", size)
-	b := &Board{
-		size:  size,
-		tiles: map[*Tile]struct{}{},
+func newBoardFromState(s rules.State) *Board {
+	return &Board{
+		state:     s,
+		history:   []step{{state: s}},
+		outside:   outsideCells(s),
+		tiles:     tilesFromState(s),
+		deadlocks: deadlock.New(s),
 	}
-	b.tiles[NewTile(PlayerSprite, StartX, StartY)] = struct{}{}
-	if err := addRandomTile(b.tiles, b.size, BoulderSprite); err != nil {
-		return nil, err
+}
+
+// outsideCells marks the floor cells that are connected to the edge of a walled board
+// but not to the player, e.g. the space around a hand-made level.
+// Portals connect their cells.
+func outsideCells(s rules.State) []bool {
+	outside := make([]bool, s.Width*s.Height)
+	if s.Edge == rules.EdgeToroidal {
+		return outside
 	}
-	for i := 0; i < blocks; i++ {
-		if err := addRandomTile(b.tiles, b.size, MountainSprite); err != nil {
-			return nil, err
+	reachable := func(start []rules.Pos) []bool {
+		seen := make([]bool, s.Width*s.Height)
+		queue := []rules.Pos{}
+		for _, p := range start {
+			if s.CellAt(p).Passable() && !seen[p.X+p.Y*s.Width] {
+				seen[p.X+p.Y*s.Width] = true
+				queue = append(queue, p)
+			}
 		}
+		for 0 < len(queue) {
+			p := queue[0]
+			queue = queue[1:]
+			for _, dir := range []Dir{DirUp, DirRight, DirDown, DirLeft} {
+				n, ok := s.Neighbor(p, dir)
+				if !ok || !s.CellAt(n).Passable() || seen[n.X+n.Y*s.Width] {
+					continue
+				}
+				seen[n.X+n.Y*s.Width] = true
+				queue = append(queue, n)
+			}
+			if partner, ok := s.PortalAt(p); ok && !seen[partner.X+partner.Y*s.Width] {
+				seen[partner.X+partner.Y*s.Width] = true
+				queue = append(queue, partner)
+			}
+		}
+		return seen
+	}
+	edge := []rules.Pos{}
+	for x := 0; x < s.Width; x++ {
+		edge = append(edge, rules.Pos{X: x, Y: 0}, rules.Pos{X: x, Y: s.Height - 1})
+	}
+	for y := 0; y < s.Height; y++ {
+		edge = append(edge, rules.Pos{X: 0, Y: y}, rules.Pos{X: s.Width - 1, Y: y})
+	}
+	inside := reachable([]rules.Pos{s.Player})
+	for i, out := range reachable(edge) {
+		outside[i] = out && !inside[i]
 	}
-	if err := addRandomTile(b.tiles, b.size, TargetSprite); err != nil {
-		return nil, err
+	return outside
+}
+
+// Reset puts the board back to its initial layout, dropping the history.
+// The time spent on the board and the hints taken are kept.
+func (b *Board) Reset() {
+	ticks, hints := b.ticks, b.hints
+	*b = *newBoardFromState(b.history[0].state)
+	b.ticks, b.hints = ticks, hints
+}
+
+// Elapsed returns the time spent on the board.
+func (b *Board) Elapsed() time.Duration {
+	return time.Duration(b.ticks) * time.Second / time.Duration(ebiten.TPS())
+}
+
+// Moves returns the directions of the moves leading to the current state.
+func (b *Board) Moves() []Dir {
+	dirs := make([]Dir, b.current)
+	for i := range dirs {
+		// The player moves first.
+		dirs[i] = b.history[i+1].result.Moves[0].Dir
 	}
-	return b, nil
+	return dirs
 }
 
 // Update updates the board state.
+// input may be nil, e.g. when the moves are played back.
 func (b *Board) Update(input *Input) error {
+	if !b.state.IsWon() {
+		b.ticks++
+	}
 	for t := range b.tiles {
 		if err := t.Update(); err != nil {
 			return err
@@ -56,6 +153,9 @@ func (b *Board) Update(input *Input) error {
 		}
 		return nil
 	}
+	if input == nil {
+		return nil
+	}
 	if dir, ok := input.Dir(); ok {
 		if err := b.Move(dir); err != nil {
 			return err
@@ -64,43 +164,84 @@ func (b *Board) Update(input *Input) error {
 	return nil
 }
 
+// gameOver returns true if the board is won and all the animations are done.
 func gameOver(b *Board) bool {
-	var bx, by, tx, ty, px, py int
-	for t := range b.tiles {
-		if t.Value() == TargetSprite {
-			tx, ty = t.current.x, t.current.y
-		}
-		if t.Value() == PlayerSprite {
-			px, py = t.current.x, t.current.y
-		}
-		if t.Value() == BoulderSprite {
-			bx, by = t.current.x, t.current.y
-		}
+	return len(b.tasks) == 0 && b.state.IsWon()
+}
+
+// Move enqueues tile moving tasks.
+// Boulders rolling down slopes afterwards are animated by follow-up tasks, one after another.
+func (b *Board) Move(dir Dir) error {
+	next, result := b.state.Apply(dir)
+	if !result.Moved() {
+		return nil
 	}
-	if bx == tx && by == ty {
-		return true
+	pushes := b.pushes
+	if result.Pushed {
+		pushes++
 	}
-	if px == tx && py == ty {
-		return true
+	b.history = append(b.history[:b.current+1], step{state: next, result: result, pushes: pushes})
+	b.goTo(b.current+1, result.Phases())
+	return nil
+}
+
+// Undo takes back the last move, animating it in reverse.
+// Undo returns false if there is nothing to undo or the board is still animating.
+func (b *Board) Undo() bool {
+	if len(b.tasks) != 0 || b.current == 0 {
+		return false
 	}
-	return false
+	// The boulders roll back up before the move is taken back.
+	phases := b.history[b.current].result.Reverse().Phases()
+	slices.Reverse(phases)
+	b.goTo(b.current-1, phases)
+	return true
 }
 
-// Move enqueues tile moving tasks.
-func (b *Board) Move(dir Dir) error {
+// Redo makes the last undone move again.
+// Redo returns false if there is nothing to redo or the board is still animating.
+func (b *Board) Redo() bool {
+	if len(b.tasks) != 0 || b.current == len(b.history)-1 {
+		return false
+	}
+	b.goTo(b.current+1, b.history[b.current+1].result.Phases())
+	return true
+}
+
+// goTo moves the board to the i-th step of history, animating the tiles by phases one after another.
+func (b *Board) goTo(i int, phases [][]rules.Move) {
 	for t := range b.tiles {
 		t.stopAnimation()
 	}
-	if !MoveTiles(b.tiles, b.size, dir) {
-		return nil
+	b.current = i
+	b.hint = nil
+	b.state = b.history[i].state
+	b.stuck = nil
+	if p, ok := b.deadlocks.Check(b.state); ok {
+		b.stuck = &p
 	}
-	b.tasks = append(b.tasks, func() error {
+	b.moves = i
+	b.pushes = b.history[i].pushes
+	wait := func() error {
 		for t := range b.tiles {
 			if t.IsMoving() {
 				return nil
 			}
 		}
 		return taskTerminated
+	}
+	animateTiles(b.tiles, phases[0])
+	b.tasks = append(b.tasks, wait)
+	for _, moves := range phases[1:] {
+		b.tasks = append(b.tasks, func() error {
+			animateTiles(b.tiles, moves)
+			return taskTerminated
+		}, wait)
+	}
+	// Gates open and close once the pieces have arrived.
+	b.tasks = append(b.tasks, func() error {
+		toggleGates(b.tiles, b.state)
+		return taskTerminated
 	})
 	b.tasks = append(b.tasks, func() error {
 		nextTiles := map[*Tile]struct{}{}
@@ -117,26 +258,25 @@ func (b *Board) Move(dir Dir) error {
 			nextTiles[t] = struct{}{}
 		}
 		b.tiles = nextTiles
-		// if err := addRandomTile(b.tiles, b.size); err != nil {
-		// 	return err
-		// }
 		return taskTerminated
 	})
-	return nil
 }
 
 // Size returns the board size.
 func (b *Board) Size() (int, int) {
-	x := b.size*tileSize + (b.size+1)*tileMargin
-	y := x
+	x := b.state.Width*tileSize + (b.state.Width+1)*tileMargin
+	y := b.state.Height*tileSize + (b.state.Height+1)*tileMargin
 	return x, y
 }
 
 // Draw draws the board to the given boardImage.
 func (b *Board) Draw(boardImage *ebiten.Image) {
 	boardImage.Fill(frameColor)
-	for j := 0; j < b.size; j++ {
-		for i := 0; i < b.size; i++ {
+	for j := 0; j < b.state.Height; j++ {
+		for i := 0; i < b.state.Width; i++ {
+			if b.outside[i+j*b.state.Width] {
+				continue
+			}
 			op := &ebiten.DrawImageOptions{}
 			x := i*tileSize + (i+1)*tileMargin
 			y := j*tileSize + (j+1)*tileMargin
@@ -145,19 +285,36 @@ func (b *Board) Draw(boardImage *ebiten.Image) {
 			boardImage.DrawImage(tileImage, op)
 		}
 	}
+	// Draw the ground (ice, pits, one-way cells, plates, gates, keys, doors and slopes) and the floor tiles (e.g. targets)
+	// first so that pieces standing on them stay visible.
+	groundTiles := map[*Tile]struct{}{}
+	floorTiles := map[*Tile]struct{}{}
+	pieceTiles := map[*Tile]struct{}{}
 	animatingTiles := map[*Tile]struct{}{}
-	nonAnimatingTiles := map[*Tile]struct{}{}
 	for t := range b.tiles {
-		if t.IsMoving() {
+		switch {
+		case isGround(t.current.value):
+			groundTiles[t] = struct{}{}
+		case t.IsMoving():
 			animatingTiles[t] = struct{}{}
-		} else {
-			nonAnimatingTiles[t] = struct{}{}
+		case t.current.value == PlayerSprite || t.current.value == BoulderSprite:
+			pieceTiles[t] = struct{}{}
+		default:
+			floorTiles[t] = struct{}{}
 		}
 	}
-	for t := range nonAnimatingTiles {
+	for t := range groundTiles {
+		t.Draw(boardImage)
+	}
+	for t := range floorTiles {
+		t.Draw(boardImage)
+	}
+	for t := range pieceTiles {
 		t.Draw(boardImage)
 	}
 	for t := range animatingTiles {
 		t.Draw(boardImage)
 	}
+	b.drawHint(boardImage)
+	b.drawStuck(boardImage)
 }
@@ -0,0 +1,337 @@
+package sisyphos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+// finishAnimations runs the board tasks until they are all done.
+func finishAnimations(t *testing.T, b *Board) {
+	for 0 < len(b.tasks) {
+		for tile := range b.tiles {
+			require.NoError(t, tile.Update())
+		}
+		if err := b.tasks[0](); err == taskTerminated {
+			b.tasks = b.tasks[1:]
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Player   rules.Pos
+		Boulders []rules.Pos
+		Dir      Dir
+		Moved    bool
+		Want     []TileData
+	}{
+		{
+			Name:   "edge",
+			Player: rules.Pos{X: 0, Y: 0},
+			Dir:    DirLeft,
+			Want:   []TileData{{PlayerSprite, 0, 0}},
+		},
+		{
+			Name:     "push against the edge",
+			Player:   rules.Pos{X: 2, Y: 0},
+			Boulders: []rules.Pos{{X: 3, Y: 0}},
+			Dir:      DirRight,
+			Want:     []TileData{{PlayerSprite, 2, 0}, {BoulderSprite, 3, 0}},
+		},
+		{
+			Name:     "push",
+			Player:   rules.Pos{X: 1, Y: 0},
+			Boulders: []rules.Pos{{X: 2, Y: 0}},
+			Dir:      DirRight,
+			Moved:    true,
+			Want:     []TileData{{PlayerSprite, 2, 0}, {BoulderSprite, 3, 0}},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			s := rules.New(4, 4)
+			s.Player = test.Player
+			s.Boulders = test.Boulders
+			b := newBoardFromState(s)
+			require.NoError(t, b.Move(test.Dir))
+			require.Equal(t, test.Moved, 0 < len(b.tasks))
+			finishAnimations(t, b)
+			for _, want := range test.Want {
+				require.NotNil(t, pieceAt(b.tiles, want.x, want.y), "%v", want)
+				require.Equal(t, want.value, pieceAt(b.tiles, want.x, want.y).current.value)
+			}
+		})
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	s := rules.New(4, 1)
+	s.Player = rules.Pos{X: 0, Y: 0}
+	s.Boulders = []rules.Pos{{X: 1, Y: 0}}
+	b := newBoardFromState(s)
+	require.False(t, b.Undo())
+
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.Equal(t, 2, b.moves)
+	require.Equal(t, 2, b.pushes)
+	require.False(t, b.Redo())
+
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+	require.Equal(t, s, b.state)
+	require.Equal(t, 0, b.moves)
+	require.Equal(t, 0, b.pushes)
+	require.Equal(t, s.Boulders[0].X, pieceAt(b.tiles, 1, 0).current.x)
+	require.Equal(t, PlayerSprite, pieceAt(b.tiles, 0, 0).current.value)
+
+	require.True(t, b.Redo())
+	finishAnimations(t, b)
+	require.Equal(t, 1, b.moves)
+	require.Equal(t, BoulderSprite, pieceAt(b.tiles, 2, 0).current.value)
+
+	// A new move drops the undone ones.
+	require.NoError(t, b.Move(DirLeft))
+	finishAnimations(t, b)
+	require.False(t, b.Redo())
+	require.Equal(t, 2, b.moves)
+	require.Equal(t, 1, b.pushes)
+}
+
+func TestReset(t *testing.T) {
+	s := rules.New(4, 1)
+	s.Boulders = []rules.Pos{{X: 1, Y: 0}}
+	b := newBoardFromState(s)
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+
+	b.Reset()
+	require.Equal(t, s, b.state)
+	require.Equal(t, 0, b.moves)
+	require.False(t, b.Undo())
+	require.Equal(t, BoulderSprite, pieceAt(b.tiles, 1, 0).current.value)
+}
+
+func TestHint(t *testing.T) {
+	// @ $.
+	s := rules.New(4, 1)
+	s.Boulders = []rules.Pos{{X: 2, Y: 0}}
+	s.Targets = []rules.Pos{{X: 3, Y: 0}}
+	b := newBoardFromState(s)
+	require.True(t, b.Hint())
+	require.Equal(t, &hint{cell: rules.Pos{X: 1, Y: 0}}, b.hint)
+
+	require.NoError(t, b.Move(DirRight))
+	require.Nil(t, b.hint)
+	finishAnimations(t, b)
+	require.True(t, b.Hint())
+	require.Equal(t, &hint{cell: rules.Pos{X: 2, Y: 0}}, b.hint)
+	require.Equal(t, 2, b.hints)
+
+	// .@$
+	s = rules.New(3, 1)
+	s.Player = rules.Pos{X: 1, Y: 0}
+	s.Boulders = []rules.Pos{{X: 2, Y: 0}}
+	s.Targets = []rules.Pos{{X: 0, Y: 0}}
+	b = newBoardFromState(s)
+	require.True(t, b.Hint())
+	require.NotEmpty(t, b.hint.msg)
+	require.Equal(t, 0, b.hints)
+}
+
+func TestStuck(t *testing.T) {
+	// @$
+	//   .
+	s := rules.New(3, 2)
+	s.Boulders = []rules.Pos{{X: 1, Y: 0}}
+	s.Targets = []rules.Pos{{X: 2, Y: 1}}
+	b := newBoardFromState(s)
+	require.NoError(t, b.Move(DirDown))
+	finishAnimations(t, b)
+	require.Nil(t, b.stuck)
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.Equal(t, &rules.Pos{X: 2, Y: 0}, b.stuck)
+	require.True(t, b.Undo())
+	require.Nil(t, b.stuck)
+}
+
+func TestIce(t *testing.T) {
+	// @~~-
+	s := rules.New(4, 1)
+	s.SetCell(rules.Pos{X: 1, Y: 0}, rules.Ice)
+	s.SetCell(rules.Pos{X: 2, Y: 0}, rules.Ice)
+	b := newBoardFromState(s)
+	require.NoError(t, b.Move(DirRight))
+	player := pieceAt(b.tiles, 0, 0)
+	require.Equal(t, 3, player.dist)
+	require.Equal(t, 3*maxMovingCount, player.movingCount)
+	finishAnimations(t, b)
+	require.Equal(t, rules.Pos{X: 3, Y: 0}, b.state.Player)
+	require.Equal(t, 1, b.moves)
+}
+
+func TestPit(t *testing.T) {
+	// @$o$.
+	s := rules.New(5, 1)
+	s.Boulders = []rules.Pos{{X: 1, Y: 0}, {X: 3, Y: 0}}
+	s.Targets = []rules.Pos{{X: 4, Y: 0}}
+	s.SetCell(rules.Pos{X: 2, Y: 0}, rules.Pit)
+	b := newBoardFromState(s)
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.Nil(t, tileAt(b.tiles, 2, 0, PitSprite, BoulderSprite))
+	require.Len(t, b.tiles, 3)
+
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+	require.NotNil(t, tileAt(b.tiles, 2, 0, PitSprite))
+	require.NotNil(t, pieceAt(b.tiles, 1, 0))
+	require.Len(t, b.tiles, 5)
+}
+
+func TestTilesFromState(t *testing.T) {
+	s := rules.New(3, 3)
+	s.Boulders = []rules.Pos{{X: 1, Y: 1}}
+	s.Targets = []rules.Pos{{X: 2, Y: 2}}
+	s.SetCell(rules.Pos{X: 1, Y: 0}, rules.Wall)
+	s.SetCell(rules.Pos{X: 2, Y: 0}, rules.Ice)
+	s.SetCell(rules.Pos{X: 0, Y: 2}, rules.Pit)
+	s.SetCell(rules.Pos{X: 0, Y: 1}, rules.OneWayDown)
+	s.SetCell(rules.Pos{X: 2, Y: 1}, rules.OneWayLeft)
+	s.SetCell(rules.Pos{X: 1, Y: 2}, rules.Plate)
+	tiles := tilesFromState(s)
+	for _, want := range []TileData{
+		{PlayerSprite, 0, 0},
+		{MountainSprite, 1, 0},
+		{IceSprite, 2, 0},
+		{OneWayDownSprite, 0, 1},
+		{BoulderSprite, 1, 1},
+		{OneWayLeftSprite, 2, 1},
+		{PitSprite, 0, 2},
+		{PlateSprite, 1, 2},
+		{TargetSprite, 2, 2},
+	} {
+		require.NotNil(t, tileAt(tiles, want.x, want.y, want.value), "%v", want)
+	}
+	require.Len(t, tiles, 9)
+}
+
+func TestPortal(t *testing.T) {
+	// @P#P-
+	s := rules.New(5, 1)
+	s.SetCell(rules.Pos{X: 2, Y: 0}, rules.Wall)
+	s.Portals = [][2]rules.Pos{{{X: 1, Y: 0}, {X: 3, Y: 0}}}
+	b := newBoardFromState(s)
+	require.NoError(t, b.Move(DirRight))
+	player := pieceAt(b.tiles, 0, 0)
+	require.NotNil(t, player.exit)
+	require.Equal(t, TileData{PlayerSprite, 1, 0}, player.next)
+	finishAnimations(t, b)
+	require.Equal(t, TileData{PlayerSprite, 4, 0}, player.current)
+	require.False(t, player.growing)
+
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+	require.Equal(t, TileData{PlayerSprite, 0, 0}, player.current)
+}
+
+func TestGate(t *testing.T) {
+	// @_|-
+	s := rules.New(4, 1)
+	s.SetCell(rules.Pos{X: 1, Y: 0}, rules.Plate)
+	s.SetCell(rules.Pos{X: 2, Y: 0}, rules.Gate)
+	s.Links = [][2]rules.Pos{{{X: 1, Y: 0}, {X: 2, Y: 0}}}
+	b := newBoardFromState(s)
+	gate := tileAt(b.tiles, 2, 0, GateSprite)
+	require.False(t, gate.open)
+	require.Equal(t, linkColors[0], gate.tint)
+
+	require.NoError(t, b.Move(DirRight))
+	// The gate opens once the player is on the plate.
+	require.False(t, gate.open)
+	finishAnimations(t, b)
+	require.True(t, gate.open)
+	require.Positive(t, gate.togglingCount)
+
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.False(t, gate.open)
+	require.NoError(t, b.Move(DirLeft))
+	finishAnimations(t, b)
+	require.Equal(t, 3, b.moves)
+
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+	require.True(t, gate.open)
+}
+
+func TestKeys(t *testing.T) {
+	// @kd-
+	s := rules.New(4, 1)
+	s.SetCell(rules.Pos{X: 1, Y: 0}, rules.Key)
+	s.SetCell(rules.Pos{X: 2, Y: 0}, rules.Door)
+	b := newBoardFromState(s)
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.Nil(t, tileAt(b.tiles, 1, 0, KeySprite))
+	require.Equal(t, 1, b.state.Keys)
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.Nil(t, tileAt(b.tiles, 2, 0, DoorSprite))
+	require.Equal(t, 0, b.state.Keys)
+	require.Len(t, b.tiles, 1)
+
+	// Undoing gives the key back and locks the door again.
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+	require.NotNil(t, tileAt(b.tiles, 2, 0, DoorSprite))
+	require.Equal(t, 1, b.state.Keys)
+	require.True(t, b.Undo())
+	finishAnimations(t, b)
+	require.NotNil(t, tileAt(b.tiles, 1, 0, KeySprite))
+	require.Equal(t, 0, b.state.Keys)
+}
+
+func TestSlope(t *testing.T) {
+	// ----
+	// @$L-
+	s := rules.New(4, 2)
+	s.Player = rules.Pos{X: 0, Y: 1}
+	s.Boulders = []rules.Pos{{X: 1, Y: 1}}
+	s.SetCell(rules.Pos{X: 2, Y: 1}, rules.SlopeLeft)
+	b := newBoardFromState(s)
+	require.NoError(t, b.Move(DirRight))
+	finishAnimations(t, b)
+	require.NotNil(t, pieceAt(b.tiles, 2, 1))
+
+	// The boulder rolls back once the player has stepped aside.
+	require.NoError(t, b.Move(DirUp))
+	boulder := pieceAt(b.tiles, 2, 1)
+	require.Equal(t, TileData{}, boulder.next)
+	finishAnimations(t, b)
+	require.Equal(t, TileData{BoulderSprite, 1, 1}, boulder.current)
+
+	// Taking the move back, the boulder rolls back up before the player returns.
+	require.True(t, b.Undo())
+	player := pieceAt(b.tiles, 1, 0)
+	require.Equal(t, TileData{}, player.next)
+	require.Equal(t, TileData{BoulderSprite, 2, 1}, boulder.next)
+	finishAnimations(t, b)
+	require.Equal(t, TileData{BoulderSprite, 2, 1}, boulder.current)
+	require.Equal(t, TileData{PlayerSprite, 1, 1}, player.current)
+}
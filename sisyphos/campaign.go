@@ -0,0 +1,59 @@
+package sisyphos
+
+import (
+	"log"
+)
+
+// startCampaign switches to the level packs, resuming at the last played level.
+func (g *Game) startCampaign() {
+	if len(levelPacks) == 0 {
+		log.Println("no level packs")
+		g.stopMode()
+		return
+	}
+	g.mode = ModeCampaign
+	g.boardImage = nil
+	g.newBoard()
+}
+
+// campaignPack returns the pack currently played.
+func (g *Game) campaignPack() levelPack {
+	return levelPacks[g.campaign.pack]
+}
+
+// loadCampaignLevel loads the current level of the current pack.
+func (g *Game) loadCampaignLevel() {
+	pack := g.campaignPack()
+	g.level = g.campaign.level
+	l := pack.levels[g.level]
+	log.Printf("level %d/%d of %s: %s", g.level+1, len(pack.levels), pack.name, l.Title)
+	g.board = newBoardFromState(l.State.Clone())
+	g.edge = l.State.Edge
+	g.scale = fitScale(g.board)
+}
+
+// completeCampaignLevel moves on to the next level, and to the next pack after the last one.
+// The game returns to the endless mode once all the packs are completed.
+func (g *Game) completeCampaignLevel() {
+	g.campaign.level++
+	if g.campaign.level < len(g.campaignPack().levels) {
+		g.newBoard()
+		return
+	}
+	log.Println("completed level pack", g.campaignPack().name)
+	g.campaign.level = 0
+	g.campaign.pack++
+	if g.campaign.pack < len(levelPacks) {
+		g.newBoard()
+		return
+	}
+	log.Println("campaign completed")
+	g.campaign.pack = 0
+	g.stopMode()
+}
+
+// fitScale returns the scale that fits b into the expected board area.
+func fitScale(b *Board) float64 {
+	w, h := b.Size()
+	return min(1.0, float64(ExpectedBoardSize*tileSize)/float64(max(w, h)))
+}
@@ -0,0 +1,19 @@
+//go:build js
+
+package sisyphos
+
+import (
+	"log"
+	"syscall/js"
+)
+
+// copyText puts text on the clipboard, logging it too in case the browser refuses.
+func copyText(text string) {
+	log.Println(text)
+	clipboard := js.Global().Get("navigator").Get("clipboard")
+	if clipboard.IsUndefined() {
+		log.Println("no clipboard access")
+		return
+	}
+	clipboard.Call("writeText", text)
+}
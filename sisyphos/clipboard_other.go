@@ -0,0 +1,12 @@
+//go:build !js
+
+package sisyphos
+
+import (
+	"log"
+)
+
+// copyText logs text, as there is no portable clipboard on native builds.
+func copyText(text string) {
+	log.Println(text)
+}
@@ -8,6 +8,30 @@ import (
 var (
 	backgroundColor = color.RGBA{75, 75, 75, 0xff}
 	frameColor      = color.RGBA{0xbb, 0xad, 0xa0, 0xff}
+	hudColor        = color.RGBA{0xee, 0xe4, 0xda, 0xff}
+	iconColor       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	iceColor        = color.RGBA{0xcf, 0xe8, 0xf3, 0xff}
+	pitColor        = color.RGBA{0x2a, 0x24, 0x1f, 0xff}
+	oneWayColor     = color.RGBA{0xf2, 0xb1, 0x79, 0xff}
+	keyColor        = color.RGBA{0xe8, 0xc5, 0x47, 0xff}
+	doorColor       = color.RGBA{0x8b, 0x5a, 0x2b, 0xff}
+	slopeColor      = color.RGBA{0xc9, 0xa2, 0x7e, 0xff}
+	hintColor       = color.NRGBA{0x8f, 0xd1, 0x6a, 0x80}
+	stuckColor      = color.NRGBA{0xe0, 0x4f, 0x3a, 0x80}
+	overlayColor    = color.NRGBA{0x00, 0x00, 0x00, 0x80}
+
+	// portalColors tell the portal pairs of a board apart.
+	portalColors = []color.Color{
+		color.RGBA{0x9b, 0x59, 0xd0, 0xff},
+		color.RGBA{0x1a, 0xa3, 0x9a, 0xff},
+		color.RGBA{0xe0, 0x7a, 0x1f, 0xff},
+	}
+	// linkColors tell the plates apart, each with the gates it opens.
+	linkColors = []color.Color{
+		color.RGBA{0xd6, 0x3e, 0x5c, 0xff},
+		color.RGBA{0x3e, 0x7c, 0xd6, 0xff},
+		color.RGBA{0x5c, 0xb8, 0x3e, 0xff},
+	}
 )
 
 func tileBackgroundColor(value SpriteType) color.Color {
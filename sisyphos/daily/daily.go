@@ -0,0 +1,89 @@
+// Package daily implements the daily challenge seeds and statistics.
+package daily
+
+import "time"
+
+// dateLayout is used for the keys of Stats.Days.
+const dateLayout = "2006-01-02"
+
+// Date returns the UTC calendar date of t as a string.
+func Date(t time.Time) string {
+	return t.UTC().Format(dateLayout)
+}
+
+// Seed returns the board seed for the UTC calendar date of t.
+// The seed reads as the date, e.g. 20241231.
+func Seed(t time.Time) uint64 {
+	t = t.UTC()
+	return uint64(t.Year()*10000 + int(t.Month())*100 + t.Day())
+}
+
+// Day holds the result of a single daily challenge.
+type Day struct {
+	Completed bool `json:"completed"`
+	// Moves is the lowest move count the challenge was completed with.
+	Moves int `json:"moves"`
+}
+
+// Stats holds the daily challenge results keyed by date.
+type Stats struct {
+	Days map[string]Day `json:"days"`
+}
+
+// Record records the completion of the challenge of t's date with the given move count.
+func (s *Stats) Record(t time.Time, moves int) {
+	if s.Days == nil {
+		s.Days = map[string]Day{}
+	}
+	date := Date(t)
+	d := s.Days[date]
+	if !d.Completed || moves < d.Moves {
+		d.Moves = moves
+	}
+	d.Completed = true
+	s.Days[date] = d
+}
+
+// Today returns the result for t's date.
+func (s *Stats) Today(t time.Time) Day {
+	return s.Days[Date(t)]
+}
+
+// Streak returns the number of consecutive completed days up to t's date.
+// An unfinished challenge of t's date does not break the streak yet.
+func (s *Stats) Streak(t time.Time) int {
+	t = t.UTC()
+	if !s.Days[Date(t)].Completed {
+		t = t.AddDate(0, 0, -1)
+	}
+	n := 0
+	for s.Days[Date(t)].Completed {
+		n++
+		t = t.AddDate(0, 0, -1)
+	}
+	return n
+}
+
+// BestStreak returns the longest run of consecutive completed days.
+func (s *Stats) BestStreak() int {
+	best := 0
+	for date, d := range s.Days {
+		if !d.Completed {
+			continue
+		}
+		t, err := time.Parse(dateLayout, date)
+		if err != nil {
+			continue
+		}
+		// Count only from the first day of each run.
+		if s.Days[Date(t.AddDate(0, 0, -1))].Completed {
+			continue
+		}
+		n := 0
+		for ; s.Days[Date(t)].Completed; t = t.AddDate(0, 0, 1) {
+			n++
+		}
+		best = max(best, n)
+	}
+	return best
+}
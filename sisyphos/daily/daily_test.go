@@ -0,0 +1,45 @@
+package daily_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/daily"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, time.December, d, 12, 0, 0, 0, time.UTC)
+}
+
+func TestSeed(t *testing.T) {
+	require.Equal(t, uint64(20241231), daily.Seed(day(31)))
+	// The seed follows the UTC date, not the local one.
+	local := time.Date(2025, time.January, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600))
+	require.Equal(t, uint64(20241231), daily.Seed(local))
+	require.Equal(t, "2024-12-31", daily.Date(local))
+}
+
+func TestRecord(t *testing.T) {
+	var s daily.Stats
+	require.False(t, s.Today(day(1)).Completed)
+	s.Record(day(1), 20)
+	s.Record(day(1), 30)
+	require.Equal(t, daily.Day{Completed: true, Moves: 20}, s.Today(day(1)))
+	s.Record(day(1), 10)
+	require.Equal(t, 10, s.Today(day(1)).Moves)
+}
+
+func TestStreak(t *testing.T) {
+	var s daily.Stats
+	require.Equal(t, 0, s.Streak(day(10)))
+	for _, d := range []int{1, 2, 3, 5, 6} {
+		s.Record(day(d), 10)
+	}
+	require.Equal(t, 2, s.Streak(day(6)))
+	// Today's challenge is still open.
+	require.Equal(t, 2, s.Streak(day(7)))
+	require.Equal(t, 0, s.Streak(day(8)))
+	require.Equal(t, 3, s.Streak(day(3)))
+	require.Equal(t, 3, s.BestStreak())
+}
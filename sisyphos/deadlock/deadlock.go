@@ -0,0 +1,158 @@
+// Package deadlock detects boards that cannot be won any more.
+//
+// The detection is conservative: a state reported as deadlocked can never be
+// won, but some states that cannot be won go undetected.
+package deadlock
+
+import (
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+// Detector checks the states of a single board.
+// It precomputes the static tables from the walls and targets of the board.
+type Detector struct {
+	width int
+	// dead marks the cells from which a boulder can never reach a target,
+	// e.g. corners without a target.
+	dead []bool
+}
+
+// New creates a Detector for the board of s. Only the grid, edge mode and targets of s matter.
+func New(s rules.State) *Detector {
+	d := &Detector{
+		width: s.Width,
+		dead:  make([]bool, s.Width*s.Height),
+	}
+	// Pull a boulder back from every target; the cells it cannot reach are dead.
+	// Boulders roll off slopes without being pushed, so slopes count as targets.
+	alive := make([]bool, s.Width*s.Height)
+	queue := []rules.Pos{}
+	for _, t := range append(slopes(s), s.Targets...) {
+		if !alive[d.index(t)] {
+			alive[d.index(t)] = true
+			queue = append(queue, t)
+		}
+	}
+	for 0 < len(queue) {
+		p := queue[0]
+		queue = queue[1:]
+		for _, dir := range []rules.Dir{rules.DirUp, rules.DirRight, rules.DirDown, rules.DirLeft} {
+			// A boulder at q is pushed to p by the player standing at r.
+			q, ok := s.Neighbor(p, dir)
+			if partner, portal := s.PortalAt(q); ok && portal {
+				// The boulder came out of the portal at q, so it went into its partner.
+				q, ok = s.Neighbor(partner, dir)
+			}
+			if !ok || !s.CellAt(q).Passable() || alive[d.index(q)] {
+				continue
+			}
+			r, ok := s.Neighbor(q, dir)
+			if !ok || !s.CellAt(r).Passable() {
+				continue
+			}
+			alive[d.index(q)] = true
+			queue = append(queue, q)
+		}
+	}
+	for i, c := range s.Grid {
+		d.dead[i] = c.Passable() && !alive[i]
+	}
+	// Boulders only pass through portals, so a portal does not stop one on its way.
+	for _, pair := range s.Portals {
+		for _, p := range pair {
+			if s.In(p) {
+				d.dead[d.index(p)] = false
+			}
+		}
+	}
+	return d
+}
+
+// slopes returns the slope cells of s.
+func slopes(s rules.State) []rules.Pos {
+	var ps []rules.Pos
+	for i, c := range s.Grid {
+		if _, ok := c.Slope(); ok {
+			ps = append(ps, rules.Pos{X: i % s.Width, Y: i / s.Width})
+		}
+	}
+	return ps
+}
+
+func (d *Detector) index(p rules.Pos) int {
+	return p.X + p.Y*d.width
+}
+
+// IsDead returns true if a boulder at p can never reach a target.
+func (d *Detector) IsDead(p rules.Pos) bool {
+	return d.dead[d.index(p)]
+}
+
+// Check returns a boulder that can never be moved onto a target in s, and true,
+// or false if no deadlock is found.
+// Boards with pits may have spare boulders, so stuck boulders only deadlock s
+// when too few boulders are left for the targets. If boulders are missing
+// because they filled pits, Check returns the first target that stays empty.
+func (d *Detector) Check(s rules.State) (rules.Pos, bool) {
+	var stuck []rules.Pos
+	for _, b := range s.Boulders {
+		if s.IsTarget(b) {
+			continue
+		}
+		if d.IsDead(b) || d.frozen(s, b, map[rules.Pos]bool{}) {
+			stuck = append(stuck, b)
+		}
+	}
+	if len(s.Targets) <= len(s.Boulders)-len(stuck) {
+		return rules.Pos{}, false
+	}
+	if 0 < len(stuck) {
+		return stuck[0], true
+	}
+	for _, t := range s.Targets {
+		if s.BoulderAt(t) < 0 {
+			return t, true
+		}
+	}
+	panic("not reach")
+}
+
+// frozen returns true if the boulder at p can move along neither axis, for good.
+// walls holds the boulders treated as walls while their neighbors are checked.
+// A boulder on a slope may still roll off, so it is never frozen.
+func (d *Detector) frozen(s rules.State, p rules.Pos, walls map[rules.Pos]bool) bool {
+	if _, ok := s.CellAt(p).Slope(); ok {
+		return false
+	}
+	walls[p] = true
+	defer delete(walls, p)
+	return d.blocked(s, p, rules.DirLeft, walls) && d.blocked(s, p, rules.DirUp, walls)
+}
+
+// blocked returns true if the boulder at p cannot move along the axis of dir.
+func (d *Detector) blocked(s rules.State, p rules.Pos, dir rules.Dir, walls map[rules.Pos]bool) bool {
+	a, okA := s.Neighbor(p, dir)
+	b, okB := s.Neighbor(p, dir.Opposite())
+	// Gates, keys and doors are not walls, as they may open or be cleared later.
+	isWall := func(q rules.Pos, ok bool) bool {
+		return !ok || s.CellAt(q) == rules.Wall || walls[q]
+	}
+	if isWall(a, okA) || isWall(b, okB) {
+		return true
+	}
+	// A boulder pushed into a pit leaves the board, even from a dead cell.
+	if s.CellAt(a) == rules.Pit || s.CellAt(b) == rules.Pit {
+		return false
+	}
+	// Moving along the axis leads onto dead cells either way.
+	if d.IsDead(a) && d.IsDead(b) {
+		return true
+	}
+	// A boulder that is stuck itself blocks the way, with p counting as a wall for it.
+	for _, q := range []rules.Pos{a, b} {
+		if 0 <= s.BoulderAt(q) && d.frozen(s, q, walls) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package deadlock_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/deadlock"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/xsb"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Board    string
+		Deadlock bool
+		Boulder  rules.Pos
+	}{
+		{
+			Name:  "free",
+			Board: "######\n#@$ .#\n######\n",
+		},
+		{
+			Name:     "corner",
+			Board:    "#####\n#@ .#\n#  $#\n#####\n",
+			Deadlock: true,
+			Boulder:  rules.Pos{X: 3, Y: 2},
+		},
+		{
+			Name:     "wall without target",
+			Board:    "#######\n#  $  #\n#@  . #\n#######\n",
+			Deadlock: true,
+			Boulder:  rules.Pos{X: 3, Y: 1},
+		},
+		{
+			Name:  "wall with target",
+			Board: "#######\n# .$  #\n#@    #\n#######\n",
+		},
+		{
+			Name:     "frozen block",
+			Board:    "#######\n#     #\n# $$  #\n# $$  #\n#@....#\n#######\n",
+			Deadlock: true,
+			Boulder:  rules.Pos{X: 2, Y: 2},
+		},
+		{
+			Name:  "frozen block on targets",
+			Board: "#######\n#     #\n# **  #\n# **  #\n#@    #\n#######\n",
+		},
+		{
+			Name:  "pair along a wall with space",
+			Board: "########\n#      #\n#  $$  #\n#@ ..  #\n########\n",
+		},
+		{
+			Name:  "spare boulder in a corner",
+			Board: "######\n#$  o#\n#@$ .#\n######\n",
+		},
+		{
+			// The gate opens once the lower boulder is on the plate.
+			Name:  "boulder against a closed gate",
+			Board: "Links: 3,2 3,1\n#######\n#@$|. #\n# $=  #\n#######\n",
+		},
+		{
+			// The right boulder fills the pit, which frees the left one.
+			Name:  "pair next to a pit",
+			Board: "######\n#   o#\n#. $$#\n#@   #\n######\n",
+		},
+		{
+			Name:  "toroidal edge",
+			Board: "Edge: Toroidal\n----\n-@$.\n----\n",
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			levels, err := xsb.ParseString(test.Board)
+			require.NoError(t, err)
+			s := levels[0].State
+			b, ok := deadlock.New(s).Check(s)
+			require.Equal(t, test.Deadlock, ok)
+			if ok {
+				require.Equal(t, test.Boulder, b)
+			}
+		})
+	}
+}
+
+func TestCheckFilledPit(t *testing.T) {
+	levels, err := xsb.ParseString("######\n#@$o.#\n#  $.#\n######\n")
+	require.NoError(t, err)
+	d := deadlock.New(levels[0].State)
+	s, result := levels[0].State.Apply(rules.DirRight)
+	require.True(t, result.Moves[1].Fill)
+	// One boulder is left for two targets.
+	target, ok := d.Check(s)
+	require.True(t, ok)
+	require.Equal(t, rules.Pos{X: 4, Y: 1}, target)
+}
+
+func TestCheckPortal(t *testing.T) {
+	// The boulder reaches the target through the portals only.
+	levels, err := xsb.ParseString("Portals: 3,1 5,1\n########\n#@$ # .#\n########\n")
+	require.NoError(t, err)
+	s := levels[0].State
+	_, ok := deadlock.New(s).Check(s)
+	require.False(t, ok)
+}
+
+func TestCheckSlope(t *testing.T) {
+	levels, err := xsb.ParseString("#####\n#   #\n#.@$#\n#####\n")
+	require.NoError(t, err)
+	s := levels[0].State
+	_, ok := deadlock.New(s).Check(s)
+	require.True(t, ok)
+
+	// The player wedges the boulder in the corner, which rolls out once the player makes way.
+	s.SetCell(rules.Pos{X: 3, Y: 2}, rules.SlopeLeft)
+	_, ok = deadlock.New(s).Check(s)
+	require.False(t, ok)
+}
+
+func TestIsDead(t *testing.T) {
+	levels, err := xsb.ParseString("#####\n#@$.#\n#   #\n#####\n")
+	require.NoError(t, err)
+	d := deadlock.New(levels[0].State)
+	require.True(t, d.IsDead(rules.Pos{X: 1, Y: 2}))
+	require.True(t, d.IsDead(rules.Pos{X: 3, Y: 2}))
+	require.False(t, d.IsDead(rules.Pos{X: 2, Y: 1}))
+	require.False(t, d.IsDead(rules.Pos{X: 3, Y: 1}))
+}
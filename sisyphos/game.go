@@ -1,19 +1,45 @@
 package sisyphos
 
 import (
+	"errors"
 	"log"
+	"math/rand/v2"
 	"runtime"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"sisyphos.optimisticotter.me/sisyphos/daily"
+	"sisyphos.optimisticotter.me/sisyphos/levelgen"
+	"sisyphos.optimisticotter.me/sisyphos/replay"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
 )
 
 const (
 	StartBoardSize = 3
 	startBlocks    = 2
+	startBoulders  = 1
 	StartX         = 1
 	StartY         = 1
 
+	// one more boulder every levelsPerBoulder levels, up to maxBoulders
+	levelsPerBoulder = 3
+	maxBoulders      = 3
+
+	// difficulty score targeted at level 0 and its growth per level
+	startDifficulty     = 9
+	difficultyPerLevel  = 3
+	difficultyBandWidth = 0.25
+
+	// score for completing an endless level, and the penalties for skipping one and for each hint
+	levelScore  = 10
+	skipPenalty = 5
+	hintPenalty = 3
+
+	// maxGenerationStates bounds the solver states explored for a single board.
+	maxGenerationStates = 20 * levelgen.DefaultAttempts * levelgen.DefaultMaxStates
+
 	tileSize   = 128
 	tileMargin = 4
 
@@ -25,8 +51,10 @@ const (
 	ScreenHeight       = tileSize * ExpectedScreenSize
 
 	// controls movement speed
-	maxMovingCount  = 5
-	maxPoppingCount = 6
+	maxMovingCount   = 5
+	maxPoppingCount  = 6
+	maxFillingCount  = 8
+	maxTogglingCount = 8
 
 	MinDragDistance = 8
 )
@@ -39,6 +67,23 @@ const (
 	BoulderSprite
 	MountainSprite
 	TargetSprite
+	IceSprite
+	PitSprite
+	PortalSprite
+	// one-way cells, in the order of rules.Dir
+	OneWayUpSprite
+	OneWayRightSprite
+	OneWayDownSprite
+	OneWayLeftSprite
+	PlateSprite
+	GateSprite
+	KeySprite
+	DoorSprite
+	// slopes, in the order of the rules.Dir they run downhill in
+	SlopeUpSprite
+	SlopeRightSprite
+	SlopeDownSprite
+	SlopeLeftSprite
 )
 
 // Game represents a game state.
@@ -49,19 +94,72 @@ type Game struct {
 	level      int
 	boardSize  int
 	scale      float64
+	edge       rules.EdgeMode
+	seed       uint64
+	mode       Mode
+	store      storage.Store
+
+	// attempt counts the boards skipped at the current endless level.
+	attempt int
+	score   int
+
+	// endless keeps the endless progress while another mode is played.
+	endless    endlessProgress
+	dailyDate  time.Time
+	dailyStats daily.Stats
+	campaign   campaignProgress
+	playback   playback
+	// generating delivers the board being generated, if any.
+	generating chan generated
 
 	sprites []*Sprite
 }
 
+// Config holds the settings a Game is started with.
+type Config struct {
+	// Seed determines the generated boards.
+	// The same seed and level always produce the same board.
+	Seed uint64
+	// Store persists the progress, settings and stats.
+	// They are kept in memory only if Store is nil.
+	Store storage.Store
+	// Resume continues the saved endless progress, including its seed, instead of starting with Seed.
+	Resume bool
+	// Replay is played back first if set.
+	Replay *replay.Replay
+	// Solution is a solution of the first board in the LURD notation.
+	// It is verified and played back if set.
+	Solution string
+}
+
 // NewGame generates a new Game object.
-func NewGame() (*Game, error) {
+func NewGame(cfg Config) (*Game, error) {
 	g := &Game{
 		input:     NewInput(),
 		level:     0,
 		boardSize: StartBoardSize,
 		scale:     1.0,
+		seed:      cfg.Seed,
+		store:     cfg.Store,
+	}
+	if g.store == nil {
+		g.store = storage.NewMemory()
+	}
+	g.loadProgress(cfg.Resume)
+	g.newBoard()
+	// There is nothing to show before the first board.
+	if g.generating != nil {
+		if err := g.finishBoard(<-g.generating); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case cfg.Replay != nil:
+		g.saveEndless()
+		g.startPlayback(cfg.Replay)
+	case cfg.Solution != "":
+		g.playSolution(cfg.Solution)
 	}
-	g.restart()
 
 	// Initialize the sprites.
 	sprites := []*Sprite{}
@@ -76,6 +174,38 @@ func NewGame() (*Game, error) {
 		},
 	}
 	sprites = append(sprites, restart)
+	undo := &Sprite{
+		image: undoImage,
+		x:     tileSize,
+		y:     0,
+		action: func() {
+			log.Println("undo button pressed")
+			g.undo()
+		},
+	}
+	sprites = append(sprites, undo)
+	skip := &Sprite{
+		image: skipImage,
+		x:     2 * tileSize,
+		y:     0,
+		action: func() {
+			log.Println("skip button pressed")
+			if g.mode == ModeEndless {
+				g.skipBoard()
+			}
+		},
+	}
+	sprites = append(sprites, skip)
+	hint := &Sprite{
+		image: hintImage,
+		x:     3 * tileSize,
+		y:     0,
+		action: func() {
+			log.Println("hint button pressed")
+			g.hint()
+		},
+	}
+	sprites = append(sprites, hint)
 
 	g.sprites = sprites
 
@@ -99,28 +229,243 @@ func (g *Game) expandBoard() {
 	g.boardImage = nil
 }
 
+// toggleEdge switches between the walled and the toroidal puzzle mode.
+func (g *Game) toggleEdge() {
+	if g.edge == rules.EdgeWalled {
+		g.edge = rules.EdgeToroidal
+	} else {
+		g.edge = rules.EdgeWalled
+	}
+	log.Println("edge mode:", g.edge)
+	g.newBoard()
+}
+
+// feature is a kind of special cell placed on the generated boards.
+type feature int
+
+const (
+	featureIce feature = iota
+	featurePit
+	featureOneWay
+	featurePortal // a pair of portals
+	featureGate   // a gate with its plate
+	featureKey    // a key with its door
+	featureSlope
+)
+
+// featureLevels holds the level each feature appears at, the number of levels
+// after which one more appears, and the most of it on a board.
+var featureLevels = [...]struct{ start, every, max int }{
+	featureIce:    {start: 5, every: 2, max: 4},
+	featurePit:    {start: 8, every: 3, max: 2},
+	featureOneWay: {start: 11, every: 2, max: 3},
+	featurePortal: {start: 14, every: 4, max: 2},
+	featureGate:   {start: 17, every: 4, max: 2},
+	featureKey:    {start: 20, every: 4, max: 2},
+	featureSlope:  {start: 23, every: 3, max: 3},
+}
+
+// featureCount returns the number of cells of f on the boards of the given level.
+// Earlier levels, and so the daily board, have none and keep their boards.
+func featureCount(f feature, level int) int {
+	l := featureLevels[f]
+	if level < l.start {
+		return 0
+	}
+	return min(1+(level-l.start)/l.every, l.max)
+}
+
+// difficultyBand returns the range of difficulty scores for the given level.
+func difficultyBand(level int) levelgen.Band {
+	d := float64(startDifficulty + difficultyPerLevel*level)
+	return levelgen.Band{
+		Min: d * (1 - difficultyBandWidth),
+		Max: d * (1 + difficultyBandWidth),
+	}
+}
+
+// restart puts the current board back to its initial layout.
+// A replay starts over.
 func (g *Game) restart() {
-	var err error
-	retries := 0
-	g.board, err = NewBoard(g.boardSize, startBlocks+g.level)
-	for err != nil {
-		g.expandBoard()
+	g.board.Reset()
+	if g.mode == ModeReplay {
+		g.playback.next = 0
+	}
+}
+
+// undo takes back the last move, unless the moves are played back.
+func (g *Game) undo() {
+	if g.mode != ModeReplay {
+		g.board.Undo()
+	}
+}
+
+// hint shows the next move, unless the moves are played back.
+func (g *Game) hint() {
+	if g.mode != ModeReplay {
+		g.board.Hint()
+	}
+}
+
+// skipBoard replaces the current board by a new one of the same level, at a cost.
+func (g *Game) skipBoard() {
+	g.attempt++
+	g.score -= skipPenalty
+	log.Println("skipped board, score:", g.score)
+	g.newBoard()
+}
+
+// newBoard starts generating the board for the current level, or loads it in the campaign mode.
+// The generation runs in the background, and the current board stays until the new one is ready.
+func (g *Game) newBoard() {
+	if g.mode == ModeCampaign {
+		g.loadCampaignLevel()
+		// Every new board is a checkpoint.
+		g.saveProgress()
+		return
+	}
+	opts := levelgen.Options{
+		Size:     g.boardSize,
+		Blocks:   startBlocks + g.level,
+		Boulders: min(startBoulders+g.level/levelsPerBoulder, maxBoulders),
+		Ice:      featureCount(featureIce, g.level),
+		Pits:     featureCount(featurePit, g.level),
+		OneWays:  featureCount(featureOneWay, g.level),
+		Portals:  featureCount(featurePortal, g.level),
+		Gates:    featureCount(featureGate, g.level),
+		Keys:     featureCount(featureKey, g.level),
+		Slopes:   featureCount(featureSlope, g.level),
+		Edge:     g.edge,
+		Player:   rules.Pos{X: StartX, Y: StartY},
+		// The first attempt keeps the stream of the level alone, skipped boards get their own.
+		Rand:       rand.New(rand.NewPCG(g.seed, uint64(g.level)|uint64(g.attempt)<<32)),
+		Solvable:   true,
+		Difficulty: difficultyBand(g.level),
+		MaxStates:  levelgen.DefaultMaxStates,
+	}
+	generating := make(chan generated, 1)
+	go func() {
+		generating <- generateBoard(opts)
+	}()
+	g.generating = generating
+}
+
+// generated represents the outcome of generateBoard.
+type generated struct {
+	state rules.State
+	// size is the board size the state was generated with.
+	size int
+	err  error
+}
 
-		g.board, err = NewBoard(g.boardSize, startBlocks+g.level)
-		// safeguard in case we can never generate the game
-		if retries > 100 {
-			panic("cannot restart game")
+// generateBoard generates a board according to opts, relaxing them until a board is found.
+// The board grows only if the pieces do not fit on it, and generateBoard gives up with
+// the last error once the solver has explored maxGenerationStates states.
+func generateBoard(opts levelgen.Options) generated {
+	explored := 0
+	for {
+		log.Println("creating board of size", opts.Size, "with", opts.Boulders, "boulders and edge mode", opts.Edge)
+		s, rating, err := levelgen.GenerateRated(opts)
+		if err == nil {
+			log.Println("board difficulty:", rating)
+			return generated{state: s, size: opts.Size}
+		}
+		log.Println(err)
+		if errors.Is(err, levelgen.ErrNoSpace) {
+			opts.Size++
+			continue
+		}
+		var budgetErr *levelgen.BudgetError
+		if !errors.As(err, &budgetErr) {
+			return generated{err: err}
 		}
-		retries += 1
+		explored += budgetErr.Explored
+		if maxGenerationStates <= explored {
+			return generated{err: err}
+		}
+		switch {
+		case 0 < budgetErr.OutOfBand:
+			// The board parameters cannot easily hit the band, accept a wider one.
+			opts.Difficulty.Min *= 1 - difficultyBandWidth
+			opts.Difficulty.Max *= 1 + difficultyBandWidth
+		case 0 < budgetErr.Unsolvable && 0 < opts.Blocks:
+			// Fewer mountains make a solvable board more likely.
+			opts.Blocks--
+		case 1 < opts.Boulders:
+			// Fewer boulders make the search cheaper.
+			opts.Boulders--
+		case 0 < budgetErr.Unsolvable:
+			// Only the special cells are left to get in the way, give them more room.
+			opts.Size++
+		default:
+			// The solver gave up on every board, let it search further.
+			opts.MaxStates *= 2
+		}
+	}
+}
+
+// finishBoard switches to the board generated by newBoard.
+// finishBoard returns the error if no board could be generated.
+func (g *Game) finishBoard(r generated) error {
+	g.generating = nil
+	if r.err != nil {
+		return r.err
+	}
+	for g.boardSize < r.size {
+		g.expandBoard()
+	}
+	g.board = newBoardFromState(r.state)
+	// Every new board is a checkpoint.
+	g.saveProgress()
+	return nil
+}
+
+// completeLevel moves on after the board was won.
+func (g *Game) completeLevel() {
+	if g.mode == ModeReplay {
+		// Stay on the final board until the playback is stopped.
+		return
+	}
+	g.saveReplay()
+	switch g.mode {
+	case ModeEndless:
+		g.score += max(0, levelScore-hintPenalty*g.board.hints)
+		g.level += 1
+		g.attempt = 0
+		g.newBoard()
+	case ModeDaily:
+		g.completeDaily()
+	case ModeCampaign:
+		g.completeCampaignLevel()
 	}
 }
 
 // Update updates the current game state.
 func (g *Game) Update() error {
 	g.input.Update()
-	if err := g.board.Update(g.input); err != nil {
+	if g.generating != nil {
+		select {
+		case r := <-g.generating:
+			if err := g.finishBoard(r); err != nil {
+				return err
+			}
+		default:
+			// The input waits for the new board.
+			return nil
+		}
+	}
+	input := g.input
+	if g.mode == ModeReplay {
+		input = nil
+	}
+	if err := g.board.Update(input); err != nil {
 		return err
 	}
+	if g.mode == ModeReplay {
+		if err := g.updatePlayback(); err != nil {
+			return err
+		}
+	}
 	for _, pos := range g.input.Clicks {
 		startSprite := g.spriteAt(pos.StartX, pos.StartY)
 		endSprite := g.spriteAt(pos.EndX, pos.EndY)
@@ -132,13 +477,47 @@ func (g *Game) Update() error {
 	if inpututil.IsKeyJustReleased(ebiten.KeyR) {
 		g.restart()
 	}
-	if gameOver(g.board) || inpututil.IsKeyJustReleased(ebiten.KeyU) {
-		g.level += 1
-		g.restart()
+	if inpututil.IsKeyJustPressed(ebiten.KeyZ) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		g.undo()
 	}
-	if inpututil.IsKeyJustReleased(ebiten.KeyP) {
-		g.expandBoard()
-		g.restart()
+	if inpututil.IsKeyJustPressed(ebiten.KeyY) && g.mode != ModeReplay {
+		g.board.Redo()
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyH) {
+		g.hint()
+	}
+	if gameOver(g.board) {
+		g.completeLevel()
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyD) {
+		g.toggleMode(ModeDaily)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyC) {
+		g.toggleMode(ModeCampaign)
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyL) {
+		g.copySolution()
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyV) || g.mode == ModeReplay && inpututil.IsKeyJustReleased(ebiten.KeyEscape) {
+		g.toggleMode(ModeReplay)
+	}
+	// The daily board must be the same for everyone, the campaign levels are fixed.
+	if g.mode == ModeEndless {
+		if inpututil.IsKeyJustReleased(ebiten.KeyU) {
+			g.level += 1
+			g.attempt = 0
+			g.newBoard()
+		}
+		if inpututil.IsKeyJustReleased(ebiten.KeyN) {
+			g.skipBoard()
+		}
+		if inpututil.IsKeyJustReleased(ebiten.KeyP) {
+			g.expandBoard()
+			g.newBoard()
+		}
+		if inpututil.IsKeyJustReleased(ebiten.KeyT) {
+			g.toggleEdge()
+		}
 	}
 	if runtime.GOOS != "js" && inpututil.IsKeyJustReleased(ebiten.KeyQ) {
 		return ebiten.Termination
@@ -148,8 +527,9 @@ func (g *Game) Update() error {
 
 // Draw draws the current game to the given screen.
 func (g *Game) Draw(screen *ebiten.Image) {
-	if g.boardImage == nil {
-		g.boardImage = ebiten.NewImage(g.board.Size())
+	// Campaign levels differ in size, so the image is recreated whenever the board does not fit it.
+	if w, h := g.board.Size(); g.boardImage == nil || g.boardImage.Bounds().Dx() != w || g.boardImage.Bounds().Dy() != h {
+		g.boardImage = ebiten.NewImage(w, h)
 	}
 	screen.Fill(backgroundColor)
 	g.board.Draw(g.boardImage)
@@ -168,4 +548,6 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	for _, s := range g.sprites {
 		s.Draw(screen, 1)
 	}
+
+	g.drawHUD(screen)
 }
@@ -4,13 +4,35 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
 )
 
 func TestCreateGame(t *testing.T) {
 	const size = 4
-	game, err := NewGame()
+	game, err := NewGame(Config{Seed: 1})
 	require.NoError(t, err)
 	for tile := range game.board.tiles {
 		t.Logf("%#v\n", tile)
 	}
 }
+
+func TestResume(t *testing.T) {
+	store := storage.NewMemory()
+	game, err := NewGame(Config{Seed: 1, Store: store})
+	require.NoError(t, err)
+	game.completeLevel()
+	game.toggleEdge()
+
+	resumed, err := NewGame(Config{Seed: 2, Store: store, Resume: true})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), resumed.seed)
+	require.Equal(t, 1, resumed.level)
+	require.Equal(t, levelScore, resumed.score)
+	require.Equal(t, rules.EdgeToroidal, resumed.edge)
+
+	fresh, err := NewGame(Config{Seed: 2, Store: store})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), fresh.seed)
+	require.Equal(t, 0, fresh.level)
+}
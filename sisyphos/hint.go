@@ -0,0 +1,59 @@
+package sisyphos
+
+import (
+	"errors"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/solver"
+)
+
+// hintMaxStates bounds the search for a hint, so that the game does not freeze on large boards.
+const hintMaxStates = 100_000
+
+// hint represents the result of asking for a hint.
+type hint struct {
+	// cell is highlighted: the cell the player should step to, or the boulder to push next.
+	cell rules.Pos
+	// msg replaces the highlight if there is no move to suggest.
+	msg string
+}
+
+// Hint searches a shortest solution from the current state and highlights its first move.
+// Hint returns false if no hint is given because the board is busy or won.
+func (b *Board) Hint() bool {
+	if len(b.tasks) != 0 || b.state.IsWon() {
+		return false
+	}
+	sol, err := solver.Solve(b.state, solver.Options{MaxStates: hintMaxStates})
+	switch {
+	case errors.Is(err, solver.ErrUnsolvable):
+		b.hint = &hint{msg: "unsolvable, undo or restart"}
+	case err != nil:
+		log.Println("no hint:", err)
+		b.hint = &hint{msg: "no hint found"}
+	default:
+		// Only a hint that suggests a move counts.
+		b.hints++
+		_, result := b.state.Apply(sol.Moves[0])
+		b.hint = &hint{cell: result.Moves[0].To}
+		if result.Pushed {
+			b.hint.cell = result.Moves[1].From
+		}
+	}
+	return true
+}
+
+// drawHint highlights the hinted cell.
+func (b *Board) drawHint(boardImage *ebiten.Image) {
+	if b.hint == nil || b.hint.msg != "" {
+		return
+	}
+	op := &ebiten.DrawImageOptions{}
+	x := b.hint.cell.X*tileSize + (b.hint.cell.X+1)*tileMargin
+	y := b.hint.cell.Y*tileSize + (b.hint.cell.Y+1)*tileMargin
+	op.GeoM.Translate(float64(x), float64(y))
+	op.ColorScale.ScaleWithColor(hintColor)
+	boardImage.DrawImage(tileImage, op)
+}
@@ -0,0 +1,147 @@
+package sisyphos
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"sisyphos.optimisticotter.me/sisyphos/daily"
+)
+
+const (
+	hudFontSize    = 24
+	hudMinFontSize = 12
+	hudLineSpacing = 1.2
+	hudMargin      = 16
+	// space between two columns of the HUD
+	hudColumnGap = 24
+)
+
+// hudLines returns the game information shown in the HUD.
+func (g *Game) hudLines() []string {
+	lines := []string{}
+	switch g.mode {
+	case ModeEndless:
+		lines = append(lines, fmt.Sprintf("level %d", g.level+1))
+		lines = append(lines, g.generatedLines(g.seed)...)
+		lines = append(lines, fmt.Sprintf("score %d", g.score))
+	case ModeDaily:
+		lines = append(lines, "daily "+daily.Date(g.dailyDate))
+		lines = append(lines, g.generatedLines(g.seed)...)
+		if d := g.dailyStats.Today(g.dailyDate); d.Completed {
+			lines = append(lines, fmt.Sprintf("best %d moves", d.Moves))
+		}
+		lines = append(lines, fmt.Sprintf("streak %d", g.dailyStats.Streak(g.dailyDate)))
+		lines = append(lines, fmt.Sprintf("best streak %d", g.dailyStats.BestStreak()))
+	case ModeCampaign:
+		pack := g.campaignPack()
+		lines = append(lines, pack.name, fmt.Sprintf("%d/%d %s", g.level+1, len(pack.levels), pack.levels[g.level].Title))
+	case ModeReplay:
+		p := g.playback
+		lines = append(lines, "replay")
+		// Replays of handcrafted boards have no size to generate them from.
+		if p.replay.Size != 0 {
+			lines = append(lines, g.generatedLines(p.replay.Seed)...)
+		}
+		lines = append(lines, fmt.Sprintf("move %d/%d", p.next, len(p.replay.Moves)))
+		if p.paused {
+			lines = append(lines, "paused")
+		} else {
+			lines = append(lines, fmt.Sprintf("speed %dx", p.speed))
+		}
+		return lines
+	}
+	lines = append(lines,
+		fmt.Sprintf("moves %d", g.board.moves),
+		fmt.Sprintf("pushes %d", g.board.pushes),
+		formatElapsed(g.board.Elapsed()),
+	)
+	if 0 < g.board.state.Keys {
+		lines = append(lines, fmt.Sprintf("keys %d", g.board.state.Keys))
+	}
+	if 0 < g.board.hints {
+		lines = append(lines, fmt.Sprintf("hints %d", g.board.hints))
+	}
+	if h := g.board.hint; h != nil && h.msg != "" {
+		lines = append(lines, h.msg)
+	}
+	if g.generating != nil {
+		lines = append(lines, "generating...")
+	}
+	return lines
+}
+
+// generatedLines returns the size of the current board and the seed it was generated from.
+func (g *Game) generatedLines(seed uint64) []string {
+	return []string{
+		fmt.Sprintf("size %dx%d", g.board.state.Width, g.board.state.Height),
+		fmt.Sprintf("seed %d", seed),
+	}
+}
+
+// formatElapsed formats d as minutes and seconds, e.g. "1:05".
+func formatElapsed(d time.Duration) string {
+	s := int(d / time.Second)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// hudColumns splits lines into columns of at most rows lines.
+func hudColumns(lines []string, rows int) [][]string {
+	columns := [][]string{}
+	for 0 < len(lines) {
+		n := min(rows, len(lines))
+		columns = append(columns, lines[:n])
+		lines = lines[n:]
+	}
+	return columns
+}
+
+// drawHUD draws the game information into the controls row, right of the buttons.
+// The lines flow into as many columns as the row height needs, and the font
+// shrinks until the columns fit the row width.
+func (g *Game) drawHUD(screen *ebiten.Image) {
+	lines := g.hudLines()
+	left := hudMargin
+	for _, s := range g.sprites {
+		left = max(left, s.x+s.image.Bounds().Dx()+hudMargin)
+	}
+	width := float64(screen.Bounds().Dx() - hudMargin - left)
+
+	var (
+		face    *text.GoTextFace
+		columns [][]string
+		widths  []float64
+	)
+	for size := float64(hudFontSize); ; size-- {
+		face = &text.GoTextFace{
+			Source: mplusFaceSource,
+			Size:   size,
+		}
+		rows := max(1, int((tileSize-2*hudMargin)/(size*hudLineSpacing)))
+		columns = hudColumns(lines, rows)
+		widths = widths[:0]
+		total := float64(hudColumnGap * (len(columns) - 1))
+		for _, c := range columns {
+			w, _ := text.Measure(strings.Join(c, "\n"), face, size*hudLineSpacing)
+			widths = append(widths, w)
+			total += w
+		}
+		if total <= width || size <= hudMinFontSize {
+			break
+		}
+	}
+
+	// The columns are aligned to the right edge of the screen.
+	x := float64(screen.Bounds().Dx() - hudMargin)
+	for i := len(columns) - 1; 0 <= i; i-- {
+		x -= widths[i]
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x, hudMargin)
+		op.LineSpacing = face.Size * hudLineSpacing
+		op.ColorScale.ScaleWithColor(hudColor)
+		text.Draw(screen, strings.Join(columns[i], "\n"), face, op)
+		x -= hudColumnGap
+	}
+}
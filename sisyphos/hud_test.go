@@ -0,0 +1,21 @@
+package sisyphos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatElapsed(t *testing.T) {
+	require.Equal(t, "0:00", formatElapsed(0))
+	require.Equal(t, "1:05", formatElapsed(65*time.Second+500*time.Millisecond))
+	require.Equal(t, "61:00", formatElapsed(time.Hour+time.Minute))
+}
+
+func TestHUDColumns(t *testing.T) {
+	lines := []string{"a", "b", "c", "d", "e"}
+	require.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, hudColumns(lines, 2))
+	require.Equal(t, [][]string{lines}, hudColumns(lines, 5))
+	require.Empty(t, hudColumns(nil, 3))
+}
@@ -6,10 +6,12 @@ import (
 	"image"
 	"image/color"
 	"log"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/examples/resources/fonts"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 //go:embed assets/*
@@ -21,8 +23,22 @@ var (
 	boulderImage  = ebiten.NewImage(tileSize, tileSize)
 	mountainImage = ebiten.NewImage(tileSize, tileSize)
 	targetImage   = ebiten.NewImage(tileSize, tileSize)
+	iceImage      = ebiten.NewImage(tileSize, tileSize)
+	pitImage      = ebiten.NewImage(tileSize, tileSize)
+	portalImage   = ebiten.NewImage(tileSize, tileSize)
+	plateImage    = ebiten.NewImage(tileSize, tileSize)
+	gateImage     = ebiten.NewImage(tileSize, tileSize)
+	keyImage      = ebiten.NewImage(tileSize, tileSize)
+	doorImage     = ebiten.NewImage(tileSize, tileSize)
+	// oneWayImages holds the one-way arrows in the order of rules.Dir.
+	oneWayImages [4]*ebiten.Image
+	// slopeImages holds the slopes in the order of the rules.Dir they run downhill in.
+	slopeImages [4]*ebiten.Image
 
 	restartImage = ebiten.NewImage(tileSize, tileSize)
+	undoImage    = ebiten.NewImage(tileSize, tileSize)
+	skipImage    = ebiten.NewImage(tileSize, tileSize)
+	hintImage    = ebiten.NewImage(tileSize, tileSize)
 
 	mplusFaceSource *text.GoTextFaceSource
 )
@@ -45,14 +61,27 @@ func init() {
 	loadImage("assets/boulder.png", boulderImage)
 	loadImage("assets/mountain.png", mountainImage)
 	loadImage("assets/vase.png", targetImage)
+	drawIceImage(iceImage)
+	drawPitImage(pitImage)
+	drawPortalImage(portalImage)
+	drawOneWayImages()
+	drawSlopeImages()
+	drawPlateImage(plateImage)
+	drawGateImage(gateImage)
+	drawKeyImage(keyImage)
+	drawDoorImage(doorImage)
 
 	loadImage("assets/restart.png", restartImage)
+	drawUndoImage(undoImage)
+	drawSkipImage(skipImage)
 
 	s, err := text.NewGoTextFaceSource(bytes.NewReader(fonts.MPlus1pRegular_ttf))
 	if err != nil {
 		log.Fatal(err)
 	}
 	mplusFaceSource = s
+
+	drawHintImage(hintImage)
 }
 
 func loadImage(path string, target *ebiten.Image) {
@@ -73,3 +102,173 @@ func loadImage(path string, target *ebiten.Image) {
 	src := ebiten.NewImageFromImage(img)
 	target.DrawImage(src, op)
 }
+
+// drawIceImage draws a pale blue floor with a few glints.
+func drawIceImage(target *ebiten.Image) {
+	const width = tileSize / 20
+	target.Fill(iceColor)
+	for _, g := range [][4]float32{
+		{0.2, 0.45, 0.45, 0.2},
+		{0.3, 0.55, 0.55, 0.3},
+		{0.5, 0.8, 0.8, 0.5},
+	} {
+		vector.StrokeLine(target, g[0]*tileSize, g[1]*tileSize, g[2]*tileSize, g[3]*tileSize, width, color.White, true)
+	}
+}
+
+// drawPitImage draws a dark hole with a rim.
+func drawPitImage(target *ebiten.Image) {
+	const (
+		mid    = tileSize * 0.5
+		radius = tileSize * 0.4
+		rim    = tileSize / 16
+	)
+	vector.DrawFilledCircle(target, mid, mid, radius, pitColor, true)
+	vector.StrokeCircle(target, mid, mid, radius, rim, frameColor, true)
+}
+
+// drawPortalImage draws white rings, which are tinted with the color of the pair.
+func drawPortalImage(target *ebiten.Image) {
+	const (
+		mid   = tileSize * 0.5
+		width = tileSize / 16
+	)
+	for _, r := range []float32{tileSize * 0.4, tileSize * 0.28, tileSize * 0.16} {
+		vector.StrokeCircle(target, mid, mid, r, width, color.White, true)
+	}
+}
+
+// drawOneWayImages draws an up arrow on the floor and rotates it for the other directions.
+func drawOneWayImages() {
+	const (
+		width = tileSize / 12
+		mid   = tileSize * 0.5
+		top   = tileSize * 0.2
+		head  = tileSize * 0.25
+	)
+	up := ebiten.NewImage(tileSize, tileSize)
+	for _, x := range []float32{tileSize * 0.3, tileSize * 0.7} {
+		vector.StrokeLine(up, mid, top, x, top+head, width, oneWayColor, true)
+		vector.StrokeLine(up, mid, top+head, x, top+2*head, width, oneWayColor, true)
+	}
+	oneWayImages = rotateImages(up)
+}
+
+// drawSlopeImages draws contour lines closing in towards the foot of a slope running
+// downhill up, and rotates them for the other directions.
+func drawSlopeImages() {
+	const width = tileSize / 24
+	up := ebiten.NewImage(tileSize, tileSize)
+	up.Fill(slopeColor)
+	for _, y := range []float32{tileSize * 0.2, tileSize * 0.35, tileSize * 0.55, tileSize * 0.8} {
+		vector.StrokeLine(up, 0, y, tileSize, y, width, frameColor, true)
+	}
+	slopeImages = rotateImages(up)
+}
+
+// rotateImages returns up rotated for each rules.Dir, up itself pointing up.
+func rotateImages(up *ebiten.Image) [4]*ebiten.Image {
+	var images [4]*ebiten.Image
+	for i := range images {
+		images[i] = ebiten.NewImage(tileSize, tileSize)
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(-tileSize/2, -tileSize/2)
+		op.GeoM.Rotate(float64(i) * math.Pi / 2)
+		op.GeoM.Translate(tileSize/2, tileSize/2)
+		images[i].DrawImage(up, op)
+	}
+	return images
+}
+
+// drawPlateImage draws a white plate, which is tinted with the color of its link.
+func drawPlateImage(target *ebiten.Image) {
+	const (
+		inset = tileSize * 0.2
+		size  = tileSize - 2*inset
+		rim   = tileSize / 16
+	)
+	vector.DrawFilledRect(target, inset, inset, size, size, color.NRGBA{0xff, 0xff, 0xff, 0x80}, true)
+	vector.StrokeRect(target, inset, inset, size, size, rim, color.White, true)
+}
+
+// drawGateImage draws white bars, which are tinted with the color of the gate's link.
+func drawGateImage(target *ebiten.Image) {
+	const (
+		width = tileSize / 12
+		top   = tileSize * 0.15
+		bot   = tileSize * 0.85
+	)
+	for _, x := range []float32{tileSize * 0.25, tileSize * 0.5, tileSize * 0.75} {
+		vector.StrokeLine(target, x, top, x, bot, width, color.White, true)
+	}
+	for _, y := range []float32{top, bot} {
+		vector.StrokeLine(target, tileSize*0.15, y, tileSize*0.85, y, width, color.White, true)
+	}
+}
+
+// drawKeyImage draws a key with its bow on the left.
+func drawKeyImage(target *ebiten.Image) {
+	const (
+		width = tileSize / 12
+		mid   = tileSize * 0.5
+		bow   = tileSize * 0.14
+		left  = tileSize * 0.32
+		right = tileSize * 0.82
+	)
+	vector.StrokeCircle(target, left, mid, bow, width, keyColor, true)
+	vector.StrokeLine(target, left+bow, mid, right, mid, width, keyColor, true)
+	for _, x := range []float32{right - width/2, right - 3*width} {
+		vector.StrokeLine(target, x, mid, x, mid+tileSize*0.14, width, keyColor, true)
+	}
+}
+
+// drawDoorImage draws a wooden door with a keyhole.
+func drawDoorImage(target *ebiten.Image) {
+	const (
+		mid  = tileSize * 0.5
+		hole = tileSize * 0.08
+	)
+	target.Fill(doorColor)
+	vector.DrawFilledCircle(target, mid, mid-hole, hole, iconColor, true)
+	vector.DrawFilledRect(target, mid-hole/2, mid-hole, hole, 3*hole, iconColor, true)
+}
+
+// drawUndoImage draws a left arrow in the style of the restart button.
+func drawUndoImage(target *ebiten.Image) {
+	const (
+		width = tileSize / 10
+		left  = tileSize * 0.25
+		right = tileSize * 0.75
+		mid   = tileSize * 0.5
+		head  = tileSize * 0.2
+	)
+	target.Fill(color.White)
+	vector.StrokeLine(target, left, mid, right, mid, width, iconColor, true)
+	vector.StrokeLine(target, left, mid, left+head, mid-head, width, iconColor, true)
+	vector.StrokeLine(target, left, mid, left+head, mid+head, width, iconColor, true)
+}
+
+// drawSkipImage draws a double right chevron in the style of the restart button.
+func drawSkipImage(target *ebiten.Image) {
+	const (
+		width = tileSize / 10
+		mid   = tileSize * 0.5
+		head  = tileSize * 0.2
+	)
+	target.Fill(color.White)
+	for _, x := range []float32{tileSize * 0.3, tileSize * 0.5} {
+		vector.StrokeLine(target, x, mid-head, x+head, mid, width, iconColor, true)
+		vector.StrokeLine(target, x+head, mid, x, mid+head, width, iconColor, true)
+	}
+}
+
+// drawHintImage draws a question mark in the style of the restart button.
+func drawHintImage(target *ebiten.Image) {
+	target.Fill(color.White)
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(tileSize/2, tileSize/2)
+	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignCenter
+	op.ColorScale.ScaleWithColor(iconColor)
+	text.Draw(target, "?", &text.GoTextFace{Source: mplusFaceSource, Size: tileSize * 0.7}, op)
+}
@@ -3,16 +3,17 @@ package sisyphos
 import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
 )
 
 // Dir represents a direction.
-type Dir int
+type Dir = rules.Dir
 
 const (
-	DirUp Dir = iota
-	DirRight
-	DirDown
-	DirLeft
+	DirUp    = rules.DirUp
+	DirRight = rules.DirRight
+	DirDown  = rules.DirDown
+	DirLeft  = rules.DirLeft
 )
 
 type mouseState int
@@ -32,36 +33,6 @@ const (
 	touchStateInvalid
 )
 
-// String returns a string representing the direction.
-func (d Dir) String() string {
-	switch d {
-	case DirUp:
-		return "Up"
-	case DirRight:
-		return "Right"
-	case DirDown:
-		return "Down"
-	case DirLeft:
-		return "Left"
-	}
-	panic("not reach")
-}
-
-// Vector returns a [-1, 1] value for each axis.
-func (d Dir) Vector() (x, y int) {
-	switch d {
-	case DirUp:
-		return 0, -1
-	case DirRight:
-		return 1, 0
-	case DirDown:
-		return 0, 1
-	case DirLeft:
-		return -1, 0
-	}
-	panic("not reach")
-}
-
 type Click struct {
 	StartX, StartY int
 	EndX, EndY     int
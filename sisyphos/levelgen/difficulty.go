@@ -0,0 +1,77 @@
+package levelgen
+
+import (
+	"fmt"
+	"math"
+
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/solver"
+)
+
+// weights of the Rating components in Rating.Score
+const (
+	movesWeight    = 1.0
+	pushesWeight   = 2.0
+	turnsWeight    = 1.0
+	deadEndsWeight = 2.0
+)
+
+// Rating describes how difficult a board is.
+type Rating struct {
+	// Moves is the length of the shortest solution.
+	Moves int
+	// Pushes is the number of pushes in the shortest solution.
+	Pushes int
+	// Turns is the number of direction changes in the shortest solution.
+	Turns int
+	// DeadEnds is the number of dead-end states met while searching for the solution.
+	// DeadEnds grows with the state space, so it adds to the score logarithmically.
+	DeadEnds int
+	// Score combines the other fields into a single difficulty value.
+	Score float64
+	// Explored is the number of states the solver expanded, which tells the work the rating took.
+	Explored int
+}
+
+func (r Rating) String() string {
+	return fmt.Sprintf("%.1f (moves: %d, pushes: %d, turns: %d, dead ends: %d)", r.Score, r.Moves, r.Pushes, r.Turns, r.DeadEnds)
+}
+
+// Band represents a range of difficulty scores. The zero Band accepts any board.
+type Band struct {
+	Min, Max float64
+}
+
+// Contains returns true if score lies within b.
+func (b Band) Contains(score float64) bool {
+	if b == (Band{}) {
+		return true
+	}
+	return b.Min <= score && score <= b.Max
+}
+
+// Rate solves s and rates its difficulty.
+// Rate returns the solver's error if s cannot be solved within maxStates,
+// together with a Rating holding only Explored.
+func Rate(s rules.State, maxStates int) (Rating, error) {
+	sol, err := solver.Solve(s, solver.Options{MaxStates: maxStates})
+	if err != nil {
+		return Rating{Explored: sol.Stats.Explored}, err
+	}
+	r := Rating{
+		Moves:    len(sol.Moves),
+		Pushes:   sol.Pushes,
+		DeadEnds: sol.Stats.DeadEnds,
+		Explored: sol.Stats.Explored,
+	}
+	for i := 1; i < len(sol.Moves); i++ {
+		if sol.Moves[i] != sol.Moves[i-1] {
+			r.Turns++
+		}
+	}
+	r.Score = movesWeight*float64(r.Moves) +
+		pushesWeight*float64(r.Pushes) +
+		turnsWeight*float64(r.Turns) +
+		deadEndsWeight*math.Log2(1+float64(r.DeadEnds))
+	return r, nil
+}
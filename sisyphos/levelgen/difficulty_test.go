@@ -0,0 +1,54 @@
+package levelgen_test
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/levelgen"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+func TestRate(t *testing.T) {
+	// @$ .
+	s := rules.New(4, 1)
+	s.Boulders = []rules.Pos{{X: 1, Y: 0}}
+	s.Targets = []rules.Pos{{X: 3, Y: 0}}
+	r, err := levelgen.Rate(s, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, r.Moves)
+	require.Equal(t, 2, r.Pushes)
+	require.Equal(t, 0, r.Turns)
+	require.Equal(t, 0, r.DeadEnds)
+	require.InDelta(t, 2+2*2, r.Score, 1e-9)
+	require.Positive(t, r.Explored)
+}
+
+func TestGenerateDifficulty(t *testing.T) {
+	band := levelgen.Band{Min: 12, Max: 20}
+	for i := 0; i < 10; i++ {
+		_, r, err := levelgen.GenerateRated(levelgen.Options{
+			Size:       4,
+			Blocks:     3,
+			Boulders:   1,
+			Player:     rules.Pos{X: 1, Y: 1},
+			Rand:       rand.New(rand.NewPCG(uint64(i), 0)),
+			Difficulty: band,
+		})
+		require.NoError(t, err)
+		require.True(t, band.Contains(r.Score), "score %v", r.Score)
+	}
+}
+
+func TestGenerateDifficultyBudget(t *testing.T) {
+	_, err := levelgen.Generate(levelgen.Options{
+		Size:       3,
+		Boulders:   1,
+		Player:     rules.Pos{X: 1, Y: 1},
+		Difficulty: levelgen.Band{Min: 1000, Max: 2000},
+		Attempts:   5,
+	})
+	var budgetErr *levelgen.BudgetError
+	require.ErrorAs(t, err, &budgetErr)
+	require.Equal(t, 5, budgetErr.Unsolvable+budgetErr.OutOfBand)
+}
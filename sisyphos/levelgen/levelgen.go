@@ -0,0 +1,240 @@
+// Package levelgen generates random sisyphos puzzles.
+package levelgen
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/solver"
+)
+
+const (
+	// DefaultAttempts is the number of boards tried when Options.Attempts is zero.
+	DefaultAttempts = 50
+	// DefaultMaxStates is the solver limit per board when Options.MaxStates is zero.
+	DefaultMaxStates = 20_000
+)
+
+// ErrNoSpace is returned when the requested pieces do not fit on the grid.
+var ErrNoSpace = errors.New("levelgen: there is no space to add a new tile")
+
+// BudgetError is returned when no suitable board was found within the budget.
+type BudgetError struct {
+	Attempts int
+	// Unsolvable is the number of boards proven unsolvable.
+	Unsolvable int
+	// OutOfBand is the number of solvable boards outside of the difficulty band.
+	// The other attempts hit the solver limit.
+	OutOfBand int
+	// Explored is the number of states the solver expanded over all the attempts.
+	Explored int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("levelgen: no suitable board in %d attempts (%d unsolvable, %d out of band)", e.Attempts, e.Unsolvable, e.OutOfBand)
+}
+
+// Options configures the generated boards.
+type Options struct {
+	Size     int
+	Blocks   int
+	Boulders int
+	// Ice is the number of ice cells, on which pieces slide until blocked.
+	Ice int
+	// Pits is the number of pits. Each pit comes with a spare boulder to fill it.
+	Pits int
+	// OneWays is the number of one-way cells, pointing in random directions.
+	OneWays int
+	// Portals is the number of portal pairs.
+	Portals int
+	// Gates is the number of gates, each linked to a plate of its own.
+	Gates int
+	// Keys is the number of keys, each with a door of its own.
+	Keys int
+	// Slopes is the number of slope cells, running downhill in random directions.
+	Slopes int
+	Edge   rules.EdgeMode
+	Player rules.Pos
+	// Rand is the source of randomness. The global source is used if Rand is nil.
+	// Generate is deterministic for a given Rand state and Options.
+	Rand *rand.Rand
+
+	// Solvable makes Generate return only boards proven solvable.
+	Solvable bool
+	// Difficulty makes Generate return only solvable boards with a score within the band.
+	Difficulty Band
+	// Attempts is the number of boards tried before giving up.
+	Attempts int
+	// MaxStates limits the solver for each attempt.
+	MaxStates int
+}
+
+// Generate creates a random board with the player at opts.Player and
+// as many targets as boulders, plus a spare boulder for every pit.
+//
+// Generate returns ErrNoSpace if the pieces do not fit on the board.
+// When opts.Solvable or opts.Difficulty is set, boards are generated until one
+// is proven solvable within the band, and a *BudgetError is returned if none
+// is found within opts.Attempts.
+func Generate(opts Options) (rules.State, error) {
+	s, _, err := GenerateRated(opts)
+	return s, err
+}
+
+// GenerateRated is like Generate, but also returns the rating of the board.
+// The rating is zero unless opts.Solvable or opts.Difficulty is set.
+func GenerateRated(opts Options) (rules.State, Rating, error) {
+	if !opts.Solvable && opts.Difficulty == (Band{}) {
+		s, err := generate(opts)
+		return s, Rating{}, err
+	}
+	attempts := opts.Attempts
+	if attempts == 0 {
+		attempts = DefaultAttempts
+	}
+	maxStates := opts.MaxStates
+	if maxStates == 0 {
+		maxStates = DefaultMaxStates
+	}
+	budgetErr := &BudgetError{Attempts: attempts}
+	for i := 0; i < attempts; i++ {
+		s, err := generate(opts)
+		if err != nil {
+			return rules.State{}, Rating{}, err
+		}
+		r, err := Rate(s, maxStates)
+		budgetErr.Explored += r.Explored
+		switch {
+		case err == nil && opts.Difficulty.Contains(r.Score):
+			return s, r, nil
+		case err == nil:
+			budgetErr.OutOfBand++
+		case errors.Is(err, solver.ErrUnsolvable):
+			budgetErr.Unsolvable++
+		case errors.Is(err, solver.ErrLimit):
+		default:
+			return rules.State{}, Rating{}, err
+		}
+	}
+	return rules.State{}, Rating{}, budgetErr
+}
+
+func generate(opts Options) (rules.State, error) {
+	s := rules.New(opts.Size, opts.Size)
+	s.Edge = opts.Edge
+	if !s.In(opts.Player) {
+		return rules.State{}, ErrNoSpace
+	}
+	s.Player = opts.Player
+	intN := rand.IntN
+	if opts.Rand != nil {
+		intN = opts.Rand.IntN
+	}
+	for i := 0; i < opts.Boulders+opts.Pits; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.Boulders = append(s.Boulders, p)
+	}
+	for i := 0; i < opts.Blocks; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(p, rules.Wall)
+	}
+	for i := 0; i < opts.Ice; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(p, rules.Ice)
+	}
+	for i := 0; i < opts.Pits; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(p, rules.Pit)
+	}
+	for i := 0; i < opts.OneWays; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(p, rules.OneWay(rules.Dir(intN(4))))
+	}
+	for i := 0; i < opts.Portals; i++ {
+		a, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		// Both ends of the pair are at a until b is picked, so that b is another cell.
+		s.Portals = append(s.Portals, [2]rules.Pos{a, a})
+		b, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.Portals[i][1] = b
+	}
+	for i := 0; i < opts.Gates; i++ {
+		plate, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(plate, rules.Plate)
+		gate, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(gate, rules.Gate)
+		s.Links = append(s.Links, [2]rules.Pos{plate, gate})
+	}
+	for i := 0; i < opts.Keys; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(p, rules.Key)
+		p, err = randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(p, rules.Door)
+	}
+	for i := 0; i < opts.Slopes; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.SetCell(p, rules.Slope(rules.Dir(intN(4))))
+	}
+	for i := 0; i < opts.Boulders; i++ {
+		p, err := randomFreeCell(s, intN)
+		if err != nil {
+			return rules.State{}, err
+		}
+		s.Targets = append(s.Targets, p)
+	}
+	return s, nil
+}
+
+func randomFreeCell(s rules.State, intN func(int) int) (rules.Pos, error) {
+	availableCells := []rules.Pos{}
+	for y := 0; y < s.Height; y++ {
+		for x := 0; x < s.Width; x++ {
+			p := rules.Pos{X: x, Y: y}
+			if _, portal := s.PortalAt(p); portal || s.CellAt(p) != rules.Floor || s.Occupied(p) || s.IsTarget(p) {
+				continue
+			}
+			availableCells = append(availableCells, p)
+		}
+	}
+	if len(availableCells) == 0 {
+		return rules.Pos{}, ErrNoSpace
+	}
+	return availableCells[intN(len(availableCells))], nil
+}
@@ -0,0 +1,206 @@
+package levelgen_test
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/levelgen"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/solver"
+)
+
+func TestGenerate(t *testing.T) {
+	opts := levelgen.Options{
+		Size:     5,
+		Blocks:   4,
+		Boulders: 2,
+		Player:   rules.Pos{X: 1, Y: 1},
+	}
+	s, err := levelgen.Generate(opts)
+	require.NoError(t, err)
+	require.Equal(t, opts.Player, s.Player)
+	require.Len(t, s.Boulders, 2)
+	require.Len(t, s.Targets, 2)
+	walls := 0
+	for _, c := range s.Grid {
+		if c == rules.Wall {
+			walls++
+		}
+	}
+	require.Equal(t, 4, walls)
+}
+
+func TestGenerateFeatures(t *testing.T) {
+	count := func(s rules.State, match func(rules.Cell) bool) int {
+		n := 0
+		for _, c := range s.Grid {
+			if match(c) {
+				n++
+			}
+		}
+		return n
+	}
+	testCases := []struct {
+		Name    string
+		Options levelgen.Options
+		Check   func(t *testing.T, s rules.State)
+	}{
+		{
+			Name:    "ice",
+			Options: levelgen.Options{Boulders: 2, Ice: 3},
+			Check: func(t *testing.T, s rules.State) {
+				require.Equal(t, 3, count(s, func(c rules.Cell) bool { return c == rules.Ice }))
+				for _, p := range append(s.Boulders, s.Targets...) {
+					require.Equal(t, rules.Floor, s.CellAt(p))
+				}
+			},
+		},
+		{
+			Name:    "pits",
+			Options: levelgen.Options{Boulders: 1, Pits: 2},
+			Check: func(t *testing.T, s rules.State) {
+				require.Equal(t, 2, count(s, func(c rules.Cell) bool { return c == rules.Pit }))
+				require.Len(t, s.Boulders, 3)
+				require.Len(t, s.Targets, 1)
+			},
+		},
+		{
+			Name:    "one-ways",
+			Options: levelgen.Options{Boulders: 1, OneWays: 3},
+			Check: func(t *testing.T, s rules.State) {
+				require.Equal(t, 3, count(s, func(c rules.Cell) bool {
+					_, ok := c.OneWay()
+					return ok
+				}))
+			},
+		},
+		{
+			Name:    "portals",
+			Options: levelgen.Options{Boulders: 1, Portals: 2},
+			Check: func(t *testing.T, s rules.State) {
+				require.Len(t, s.Portals, 2)
+				cells := map[rules.Pos]bool{}
+				for _, pair := range s.Portals {
+					for _, p := range pair {
+						require.Equal(t, rules.Floor, s.CellAt(p))
+						require.False(t, s.Occupied(p))
+						cells[p] = true
+					}
+				}
+				require.Len(t, cells, 4)
+			},
+		},
+		{
+			Name:    "gates",
+			Options: levelgen.Options{Boulders: 1, Gates: 2},
+			Check: func(t *testing.T, s rules.State) {
+				require.Len(t, s.Links, 2)
+				for _, l := range s.Links {
+					require.Equal(t, rules.Plate, s.CellAt(l[0]))
+					require.Equal(t, rules.Gate, s.CellAt(l[1]))
+					require.False(t, s.Occupied(l[0]))
+					require.False(t, s.Occupied(l[1]))
+				}
+			},
+		},
+		{
+			Name:    "keys",
+			Options: levelgen.Options{Boulders: 1, Keys: 2},
+			Check: func(t *testing.T, s rules.State) {
+				require.Equal(t, 2, count(s, func(c rules.Cell) bool { return c == rules.Key }))
+				require.Equal(t, 2, count(s, func(c rules.Cell) bool { return c == rules.Door }))
+			},
+		},
+		{
+			Name:    "slopes",
+			Options: levelgen.Options{Boulders: 1, Slopes: 3},
+			Check: func(t *testing.T, s rules.State) {
+				require.Equal(t, 3, count(s, func(c rules.Cell) bool {
+					_, ok := c.Slope()
+					return ok
+				}))
+				for _, b := range s.Boulders {
+					_, ok := s.CellAt(b).Slope()
+					require.False(t, ok)
+				}
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			opts := test.Options
+			opts.Size = 5
+			opts.Blocks = 2
+			opts.Player = rules.Pos{X: 1, Y: 1}
+			opts.Rand = rand.New(rand.NewPCG(1, 0))
+			opts.Solvable = true
+			s, err := levelgen.Generate(opts)
+			require.NoError(t, err)
+			test.Check(t, s)
+		})
+	}
+}
+
+func TestGenerateSolvable(t *testing.T) {
+	for _, edge := range []rules.EdgeMode{rules.EdgeWalled, rules.EdgeToroidal} {
+		for i := 0; i < 20; i++ {
+			s, err := levelgen.Generate(levelgen.Options{
+				Size:     4,
+				Blocks:   3,
+				Boulders: 1,
+				Edge:     edge,
+				Player:   rules.Pos{X: 1, Y: 1},
+				Rand:     rand.New(rand.NewPCG(uint64(i), uint64(edge))),
+				Solvable: true,
+			})
+			require.NoError(t, err)
+			require.Equal(t, edge, s.Edge)
+			_, err = solver.Solve(s, solver.Options{})
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	generate := func(seed uint64) rules.State {
+		s, err := levelgen.Generate(levelgen.Options{
+			Size:     5,
+			Blocks:   5,
+			Boulders: 2,
+			Player:   rules.Pos{X: 1, Y: 1},
+			Rand:     rand.New(rand.NewPCG(seed, 0)),
+			Solvable: true,
+		})
+		require.NoError(t, err)
+		return s
+	}
+	require.Equal(t, generate(1), generate(1))
+	require.NotEqual(t, generate(1), generate(2))
+}
+
+func TestGenerateNoSpace(t *testing.T) {
+	_, err := levelgen.Generate(levelgen.Options{
+		Size:     3,
+		Blocks:   7,
+		Boulders: 1,
+		Player:   rules.Pos{X: 1, Y: 1},
+	})
+	require.ErrorIs(t, err, levelgen.ErrNoSpace)
+}
+
+func TestGenerateBudget(t *testing.T) {
+	// Every cell of a walled 2x2 board is a corner.
+	_, err := levelgen.Generate(levelgen.Options{
+		Size:     2,
+		Boulders: 1,
+		Player:   rules.Pos{X: 1, Y: 1},
+		Solvable: true,
+		Attempts: 10,
+	})
+	var budgetErr *levelgen.BudgetError
+	require.ErrorAs(t, err, &budgetErr)
+	require.Equal(t, 10, budgetErr.Attempts)
+	require.Equal(t, 10, budgetErr.Unsolvable)
+	require.Positive(t, budgetErr.Explored)
+}
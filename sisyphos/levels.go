@@ -0,0 +1,65 @@
+package sisyphos
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"unicode"
+
+	"sisyphos.optimisticotter.me/sisyphos/xsb"
+)
+
+// levelPacksGlob matches the embedded level packs.
+// The packs are played in the order of their file names, e.g. "01-first-steps.xsb".
+const levelPacksGlob = "assets/levels/*.xsb"
+
+// levelPack represents a curated sequence of levels.
+type levelPack struct {
+	name   string
+	levels []xsb.Level
+}
+
+var levelPacks []levelPack
+
+func init() {
+	levelPacks = loadLevelPacks(assetsFolder, levelPacksGlob)
+}
+
+// loadLevelPacks parses all the packs matching pattern.
+func loadLevelPacks(fsys fs.FS, pattern string) []levelPack {
+	// fs.Glob returns the names sorted.
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		panic(err)
+	}
+	packs := []levelPack{}
+	for _, name := range names {
+		f, err := fsys.Open(name)
+		if err != nil {
+			panic(err)
+		}
+		levels, err := xsb.Parse(f)
+		f.Close()
+		if err != nil {
+			panic(name + ": " + err.Error())
+		}
+		if len(levels) == 0 {
+			continue
+		}
+		packs = append(packs, levelPack{name: packName(name), levels: levels})
+	}
+	return packs
+}
+
+// packName returns the display name of a pack file, e.g. "First steps" for "01-first-steps.xsb".
+func packName(file string) string {
+	name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+	name = strings.TrimLeft(name, "0123456789")
+	name = strings.TrimLeft(name, "-_")
+	name = strings.ReplaceAll(name, "-", " ")
+	r := []rune(name)
+	if 0 < len(r) {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
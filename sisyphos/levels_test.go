@@ -0,0 +1,27 @@
+package sisyphos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/solver"
+)
+
+func TestLevelPacks(t *testing.T) {
+	require.NotEmpty(t, levelPacks)
+	require.Equal(t, "First steps", levelPacks[0].name)
+	for _, pack := range levelPacks {
+		for _, l := range pack.levels {
+			t.Run(pack.name+"/"+l.Title, func(t *testing.T) {
+				require.NotEmpty(t, l.Title)
+				_, err := solver.Solve(l.State, solver.Options{})
+				require.NoError(t, err)
+			})
+		}
+	}
+}
+
+func TestPackName(t *testing.T) {
+	require.Equal(t, "First steps", packName("assets/levels/01-first-steps.xsb"))
+	require.Equal(t, "Extra", packName("extra.xsb"))
+}
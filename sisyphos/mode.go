@@ -0,0 +1,125 @@
+package sisyphos
+
+import (
+	"log"
+	"time"
+
+	"sisyphos.optimisticotter.me/sisyphos/daily"
+	"sisyphos.optimisticotter.me/sisyphos/replay"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+// Mode represents a way of playing the game.
+type Mode int
+
+const (
+	// ModeEndless generates ever harder boards from the game seed.
+	ModeEndless Mode = iota
+	// ModeDaily plays a single board seeded by the UTC date.
+	ModeDaily
+	// ModeCampaign plays the embedded level packs in order.
+	ModeCampaign
+	// ModeReplay plays back a recorded game.
+	ModeReplay
+)
+
+// dailyLevel is the difficulty level of the daily board.
+const dailyLevel = 4
+
+// endlessProgress represents the endless mode state kept while another mode is played.
+type endlessProgress struct {
+	level     int
+	boardSize int
+	scale     float64
+	edge      rules.EdgeMode
+	seed      uint64
+	attempt   int
+}
+
+// campaignProgress represents the position in the level packs.
+type campaignProgress struct {
+	pack  int
+	level int
+}
+
+// toggleMode switches to mode m, or back to the endless mode if m is already played.
+func (g *Game) toggleMode(m Mode) {
+	if g.mode == m {
+		m = ModeEndless
+	}
+	var r *replay.Replay
+	if m == ModeReplay {
+		if r = g.loadReplay(); r == nil {
+			return
+		}
+	}
+	if g.mode == ModeEndless {
+		g.saveEndless()
+	}
+	switch m {
+	case ModeEndless:
+		g.stopMode()
+	case ModeDaily:
+		g.startDaily()
+	case ModeCampaign:
+		g.startCampaign()
+	case ModeReplay:
+		g.startPlayback(r)
+	}
+}
+
+// saveEndless keeps the endless progress while another mode is played.
+func (g *Game) saveEndless() {
+	g.endless = g.endlessState()
+}
+
+// endlessState returns the endless progress, whichever mode is played.
+func (g *Game) endlessState() endlessProgress {
+	if g.mode != ModeEndless {
+		return g.endless
+	}
+	return endlessProgress{
+		level:     g.level,
+		boardSize: g.boardSize,
+		scale:     g.scale,
+		edge:      g.edge,
+		seed:      g.seed,
+		attempt:   g.attempt,
+	}
+}
+
+// startDaily switches to today's daily board.
+func (g *Game) startDaily() {
+	g.mode = ModeDaily
+	g.dailyDate = time.Now()
+	// Everything the board depends on is fixed, so that everyone gets the same board.
+	g.level = dailyLevel
+	g.boardSize = StartBoardSize
+	g.scale = 1.0
+	g.edge = rules.EdgeWalled
+	g.seed = daily.Seed(g.dailyDate)
+	g.attempt = 0
+	g.boardImage = nil
+	log.Println("daily challenge", daily.Date(g.dailyDate))
+	g.newBoard()
+}
+
+// stopMode switches back to the endless mode,
+// restoring the progress saved when another mode was started.
+func (g *Game) stopMode() {
+	g.mode = ModeEndless
+	g.level = g.endless.level
+	g.boardSize = g.endless.boardSize
+	g.scale = g.endless.scale
+	g.edge = g.endless.edge
+	g.seed = g.endless.seed
+	g.attempt = g.endless.attempt
+	g.boardImage = nil
+	g.newBoard()
+}
+
+func (g *Game) completeDaily() {
+	g.dailyStats.Record(g.dailyDate, g.board.moves)
+	log.Println("daily challenge completed in", g.board.moves, "moves, streak:", g.dailyStats.Streak(g.dailyDate))
+	g.stopMode()
+}
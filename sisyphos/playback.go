@@ -0,0 +1,155 @@
+package sisyphos
+
+import (
+	"errors"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"sisyphos.optimisticotter.me/sisyphos/replay"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
+)
+
+const (
+	replayKey = "replay"
+
+	// frames between two replayed moves at normal speed
+	playbackDelay    = 4 * maxMovingCount
+	maxPlaybackSpeed = 8
+)
+
+// playback represents the state of a replay being played back.
+type playback struct {
+	replay *replay.Replay
+	// next is the index of the next move to play.
+	next   int
+	paused bool
+	// speed multiplies the normal speed, it is a power of two.
+	speed int
+	wait  int
+}
+
+// recordReplay returns the replay of the moves made on the current board.
+func (g *Game) recordReplay() *replay.Replay {
+	r := replay.New(g.board.history[0].state)
+	r.Seed = g.seed
+	r.Level = g.level
+	r.Attempt = g.attempt
+	if g.mode != ModeCampaign {
+		r.Size = g.boardSize
+	}
+	r.Moves = g.board.Moves()
+	return r
+}
+
+// saveReplay keeps the replay of the current board, so that it can be watched with V.
+func (g *Game) saveReplay() {
+	r := g.recordReplay()
+	data, err := r.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	log.Println("replay:", r)
+	if lurd, err := rules.FormatLURD(r.Start, r.Moves); err == nil {
+		log.Println("solution:", lurd)
+	}
+	if err := g.store.Save(replayKey, data); err != nil {
+		log.Println("cannot save replay:", err)
+	}
+}
+
+// loadReplay returns the last saved replay, or nil if there is none.
+func (g *Game) loadReplay() *replay.Replay {
+	data, err := g.store.Load(replayKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Println("no replay saved yet")
+		return nil
+	}
+	if err != nil {
+		log.Println("cannot load replay:", err)
+		return nil
+	}
+	r := &replay.Replay{}
+	if err := r.UnmarshalBinary(data); err != nil {
+		log.Println("cannot load replay:", err)
+		return nil
+	}
+	return r
+}
+
+// copySolution copies the solution of the last completed board in the LURD notation.
+func (g *Game) copySolution() {
+	r := g.loadReplay()
+	if r == nil {
+		return
+	}
+	lurd, err := rules.FormatLURD(r.Start, r.Moves)
+	if err != nil {
+		log.Println("cannot copy solution:", err)
+		return
+	}
+	copyText(lurd)
+}
+
+// playSolution checks a solution in the LURD notation against the current board and plays it back.
+func (g *Game) playSolution(lurd string) {
+	start := g.board.history[0].state
+	end, err := rules.ValidateLURD(start, lurd)
+	if err != nil {
+		log.Println("invalid solution:", err)
+		return
+	}
+	if !end.IsWon() {
+		log.Println("the solution does not solve the board")
+	}
+	r := g.recordReplay()
+	// ValidateLURD has checked the letters already.
+	r.Moves, _ = rules.ParseLURD(lurd)
+	g.saveEndless()
+	g.startPlayback(r)
+}
+
+// startPlayback switches to playing back r.
+func (g *Game) startPlayback(r *replay.Replay) {
+	if _, err := r.Final(); err != nil {
+		// The moves are still played as far as possible.
+		log.Println(err)
+	}
+	g.mode = ModeReplay
+	g.playback = playback{replay: r, speed: 1}
+	g.board = newBoardFromState(r.Start.Clone())
+	g.scale = fitScale(g.board)
+	log.Println("playing back", len(r.Moves), "moves")
+}
+
+// updatePlayback handles the playback controls and feeds the recorded moves to the board.
+func (g *Game) updatePlayback() error {
+	p := &g.playback
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		p.paused = !p.paused
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) && p.speed < maxPlaybackSpeed {
+		p.speed *= 2
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) && 1 < p.speed {
+		p.speed /= 2
+	}
+	step := p.paused && inpututil.IsKeyJustPressed(ebiten.KeyRight)
+	if len(g.board.tasks) != 0 || p.next == len(p.replay.Moves) {
+		return nil
+	}
+	if !step {
+		if p.paused {
+			return nil
+		}
+		if 0 < p.wait {
+			p.wait--
+			return nil
+		}
+	}
+	p.wait = playbackDelay / p.speed
+	dir := p.replay.Moves[p.next]
+	p.next++
+	return g.board.Move(dir)
+}
@@ -0,0 +1,61 @@
+package sisyphos
+
+import (
+	"errors"
+	"log"
+
+	"sisyphos.optimisticotter.me/sisyphos/save"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
+)
+
+// loadProgress restores the saved stats, campaign position and settings,
+// and the endless progress too if resume is true.
+func (g *Game) loadProgress(resume bool) {
+	s, err := save.Load(g.store)
+	if errors.Is(err, storage.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Println("cannot load save:", err)
+		return
+	}
+	g.dailyStats = s.Daily
+	if 0 <= s.Campaign.Pack && s.Campaign.Pack < len(levelPacks) &&
+		0 <= s.Campaign.Level && s.Campaign.Level < len(levelPacks[s.Campaign.Pack].levels) {
+		g.campaign = campaignProgress{pack: s.Campaign.Pack, level: s.Campaign.Level}
+	}
+	g.edge = s.Settings.Edge
+	if !resume || s.Endless == nil {
+		return
+	}
+	g.seed = s.Endless.Seed
+	g.level = s.Endless.Level
+	g.attempt = s.Endless.Attempt
+	g.score = s.Endless.Score
+	g.boardSize = max(s.Endless.BoardSize, StartBoardSize)
+	if 0 < s.Endless.Scale {
+		g.scale = s.Endless.Scale
+	}
+	log.Println("resuming level", g.level, "of seed", g.seed)
+}
+
+// saveProgress persists the stats, the progress of all the modes and the settings.
+func (g *Game) saveProgress() {
+	e := g.endlessState()
+	s := save.Save{
+		Endless: &save.Endless{
+			Seed:      e.seed,
+			Level:     e.level,
+			Attempt:   e.attempt,
+			Score:     g.score,
+			BoardSize: e.boardSize,
+			Scale:     e.scale,
+		},
+		Campaign: save.Campaign{Pack: g.campaign.pack, Level: g.campaign.level},
+		Settings: save.Settings{Edge: e.edge},
+		Daily:    g.dailyStats,
+	}
+	if err := save.Write(g.store, s); err != nil {
+		log.Println("cannot save:", err)
+	}
+}
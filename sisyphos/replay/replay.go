@@ -0,0 +1,270 @@
+// Package replay records games in a compact binary format.
+//
+// A replay holds the initial board together with the moves made on it, so
+// that it plays back exactly even if the board generation changes. The seed,
+// level and board size are kept too, to tell where the board came from.
+package replay
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+// magic starts every replay.
+const magic = "SSRP"
+
+// FormatVersion is the version of the binary format written by MarshalBinary.
+const FormatVersion = 1
+
+var (
+	// ErrFormat is returned when the data is not a valid replay.
+	ErrFormat = errors.New("replay: invalid format")
+	// ErrVersion is returned when the data was written by a newer format version.
+	ErrVersion = errors.New("replay: unsupported format version")
+)
+
+// Replay represents a recorded game.
+type Replay struct {
+	// RulesVersion is the rules.Version the game was played with.
+	RulesVersion int
+	Seed         uint64
+	Level        int
+	// Attempt counts the boards skipped at Level before this one.
+	Attempt int
+	// Size is the size the board was generated with, or 0 for handcrafted boards.
+	Size  int
+	Start rules.State
+	Moves []rules.Dir
+}
+
+// New starts a replay of s with the current rules.
+func New(s rules.State) *Replay {
+	return &Replay{
+		RulesVersion: rules.Version,
+		Start:        s.Clone(),
+	}
+}
+
+// MoveError reports a recorded move that cannot be made.
+type MoveError struct {
+	// Index is the 0-based index of the move in Moves.
+	Index int
+	Dir   rules.Dir
+}
+
+func (e *MoveError) Error() string {
+	return fmt.Sprintf("replay: move %d (%s) is not possible", e.Index+1, e.Dir)
+}
+
+// Final plays all the moves and returns the resulting state.
+// Final returns a *MoveError if a move does not move the player,
+// and an error if the replay was recorded with different rules.
+func (r *Replay) Final() (rules.State, error) {
+	if r.RulesVersion != rules.Version {
+		return rules.State{}, fmt.Errorf("replay: recorded with rules version %d, have %d", r.RulesVersion, rules.Version)
+	}
+	s := r.Start
+	for i, dir := range r.Moves {
+		next, result := s.Apply(dir)
+		if !result.Moved() {
+			return s, &MoveError{Index: i, Dir: dir}
+		}
+		s = next
+	}
+	return s, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The layout is the magic, the format version byte, then unsigned varints for
+// the rules version, seed, level, attempt, size, width, height and edge mode,
+// a byte per cell holding its rules.Cell, the cell indices of the player, the
+// boulders, the targets, the portal pairs and the plate and gate links (each list prefixed by its length),
+// the number of keys held, and finally the number of moves followed by the moves packed four per byte.
+func (r *Replay) MarshalBinary() ([]byte, error) {
+	s := r.Start
+	b := []byte(magic)
+	b = append(b, FormatVersion)
+	for _, v := range []uint64{
+		uint64(r.RulesVersion), r.Seed, uint64(r.Level), uint64(r.Attempt), uint64(r.Size),
+		uint64(s.Width), uint64(s.Height), uint64(s.Edge),
+	} {
+		b = binary.AppendUvarint(b, v)
+	}
+	for _, c := range s.Grid {
+		b = append(b, byte(c))
+	}
+	b = binary.AppendUvarint(b, uint64(index(s, s.Player)))
+	for _, ps := range [][]rules.Pos{s.Boulders, s.Targets} {
+		b = binary.AppendUvarint(b, uint64(len(ps)))
+		for _, p := range ps {
+			b = binary.AppendUvarint(b, uint64(index(s, p)))
+		}
+	}
+	for _, pairs := range [][][2]rules.Pos{s.Portals, s.Links} {
+		b = binary.AppendUvarint(b, uint64(len(pairs)))
+		for _, pair := range pairs {
+			b = binary.AppendUvarint(b, uint64(index(s, pair[0])))
+			b = binary.AppendUvarint(b, uint64(index(s, pair[1])))
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(s.Keys))
+	b = binary.AppendUvarint(b, uint64(len(r.Moves)))
+	moves := make([]byte, (len(r.Moves)+3)/4)
+	for i, dir := range r.Moves {
+		moves[i/4] |= byte(dir) << (2 * (i % 4))
+	}
+	return append(b, moves...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (r *Replay) UnmarshalBinary(data []byte) error {
+	rd := bytes.NewReader(data)
+	head := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(rd, head); err != nil || string(head[:len(magic)]) != magic {
+		return ErrFormat
+	}
+	if FormatVersion < head[len(magic)] {
+		return ErrVersion
+	}
+	var v [8]uint64
+	for i := range v {
+		var err error
+		if v[i], err = binary.ReadUvarint(rd); err != nil {
+			return ErrFormat
+		}
+	}
+	width, height, edge := int(v[5]), int(v[6]), rules.EdgeMode(v[7])
+	// Dividing keeps huge sizes from overflowing the product.
+	if width <= 0 || height <= 0 || (1<<16)/height < width || edge != rules.EdgeWalled && edge != rules.EdgeToroidal {
+		return ErrFormat
+	}
+	s := rules.New(width, height)
+	s.Edge = edge
+	cells := make([]byte, len(s.Grid))
+	if _, err := io.ReadFull(rd, cells); err != nil {
+		return ErrFormat
+	}
+	for i, c := range cells {
+		if !rules.Cell(c).Valid() {
+			return ErrFormat
+		}
+		s.Grid[i] = rules.Cell(c)
+	}
+	readPos := func() (rules.Pos, error) {
+		i, err := binary.ReadUvarint(rd)
+		if err != nil || uint64(width*height) <= i {
+			return rules.Pos{}, ErrFormat
+		}
+		return rules.Pos{X: int(i) % width, Y: int(i) / width}, nil
+	}
+	readPositions := func() ([]rules.Pos, error) {
+		n, err := binary.ReadUvarint(rd)
+		if err != nil || uint64(width*height) < n {
+			return nil, ErrFormat
+		}
+		var ps []rules.Pos
+		for ; 0 < n; n-- {
+			p, err := readPos()
+			if err != nil {
+				return nil, err
+			}
+			ps = append(ps, p)
+		}
+		return ps, nil
+	}
+	readPairs := func() ([][2]rules.Pos, error) {
+		n, err := binary.ReadUvarint(rd)
+		if err != nil || uint64(width*height) < n {
+			return nil, ErrFormat
+		}
+		var pairs [][2]rules.Pos
+		for ; 0 < n; n-- {
+			var pair [2]rules.Pos
+			for i := range pair {
+				if pair[i], err = readPos(); err != nil {
+					return nil, err
+				}
+			}
+			pairs = append(pairs, pair)
+		}
+		return pairs, nil
+	}
+	var err error
+	if s.Player, err = readPos(); err != nil {
+		return err
+	}
+	if s.Boulders, err = readPositions(); err != nil {
+		return err
+	}
+	if s.Targets, err = readPositions(); err != nil {
+		return err
+	}
+	if s.Portals, err = readPairs(); err != nil {
+		return err
+	}
+	if s.Links, err = readPairs(); err != nil {
+		return err
+	}
+	keys, err := binary.ReadUvarint(rd)
+	if err != nil || uint64(width*height) < keys {
+		return ErrFormat
+	}
+	s.Keys = int(keys)
+	if s.Validate() != nil {
+		return ErrFormat
+	}
+	n, err := binary.ReadUvarint(rd)
+	if err != nil || uint64(rd.Len())*4 < n {
+		return ErrFormat
+	}
+	moves := make([]byte, (n+3)/4)
+	if _, err := io.ReadFull(rd, moves); err != nil {
+		return ErrFormat
+	}
+	*r = Replay{
+		RulesVersion: int(v[0]),
+		Seed:         v[1],
+		Level:        int(v[2]),
+		Attempt:      int(v[3]),
+		Size:         int(v[4]),
+		Start:        s,
+		Moves:        make([]rules.Dir, n),
+	}
+	for i := range r.Moves {
+		r.Moves[i] = rules.Dir(moves[i/4] >> (2 * (i % 4)) & 3)
+	}
+	return nil
+}
+
+// String returns the replay encoded as URL-safe base64, e.g. for sharing it as a link.
+func (r *Replay) String() string {
+	data, err := r.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Parse decodes a replay encoded by String.
+func Parse(s string) (*Replay, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrFormat
+	}
+	r := &Replay{}
+	if err := r.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func index(s rules.State, p rules.Pos) int {
+	return p.X + p.Y*s.Width
+}
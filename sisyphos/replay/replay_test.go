@@ -0,0 +1,128 @@
+package replay_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/replay"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/xsb"
+)
+
+func newReplay(t *testing.T, board string, moves ...rules.Dir) *replay.Replay {
+	levels, err := xsb.ParseString(board)
+	require.NoError(t, err)
+	r := replay.New(levels[0].State)
+	r.Seed = 42
+	r.Level = 3
+	r.Attempt = 1
+	r.Size = 5
+	r.Moves = moves
+	return r
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := newReplay(t, "  ####\n###  #\n#@$  #\n#  $.#\n##  .#\n #####\n",
+		rules.DirRight, rules.DirRight, rules.DirDown, rules.DirLeft, rules.DirUp)
+	r.Start.SetCell(rules.Pos{X: 2, Y: 4}, rules.Ice)
+	r.Start.Portals = [][2]rules.Pos{{{X: 3, Y: 2}, {X: 3, Y: 4}}}
+	r.Start.SetCell(rules.Pos{X: 1, Y: 3}, rules.Plate)
+	r.Start.SetCell(rules.Pos{X: 2, Y: 3}, rules.Gate)
+	r.Start.Links = [][2]rules.Pos{{{X: 1, Y: 3}, {X: 2, Y: 3}}}
+	r.Start.SetCell(rules.Pos{X: 4, Y: 1}, rules.Door)
+	r.Start.Keys = 1
+	data, err := r.MarshalBinary()
+	require.NoError(t, err)
+
+	again := &replay.Replay{}
+	require.NoError(t, again.UnmarshalBinary(data))
+	require.Equal(t, r, again)
+
+	parsed, err := replay.Parse(r.String())
+	require.NoError(t, err)
+	require.Equal(t, r, parsed)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	data, err := newReplay(t, "#####\n#@$.#\n#####\n", rules.DirRight).MarshalBinary()
+	require.NoError(t, err)
+
+	r := &replay.Replay{}
+	require.ErrorIs(t, r.UnmarshalBinary(data[:len(data)-1]), replay.ErrFormat)
+	require.ErrorIs(t, r.UnmarshalBinary([]byte("nope")), replay.ErrFormat)
+	newer := append([]byte(nil), data...)
+	newer[4] = replay.FormatVersion + 1
+	require.ErrorIs(t, r.UnmarshalBinary(newer), replay.ErrVersion)
+
+	// The product of width and height overflows to a small size.
+	huge := []byte("SSRP\x01")
+	for _, v := range []uint64{1, 42, 3, 0, 4, 1<<32 + 1, 1<<32 - 1, uint64(rules.EdgeWalled)} {
+		huge = binary.AppendUvarint(huge, v)
+	}
+	require.ErrorIs(t, r.UnmarshalBinary(huge), replay.ErrFormat)
+
+	// The states cannot come from a board.
+	testCases := []struct {
+		Name string
+		Edit func(s *rules.State)
+	}{
+		{
+			Name: "two boulders on a cell",
+			Edit: func(s *rules.State) { s.Boulders = append(s.Boulders, s.Boulders[0]) },
+		},
+		{
+			Name: "boulder on the player",
+			Edit: func(s *rules.State) { s.Boulders[0] = s.Player },
+		},
+		{
+			Name: "boulder on a wall",
+			Edit: func(s *rules.State) { s.Boulders[0] = rules.Pos{X: 0, Y: 1} },
+		},
+		{
+			Name: "player on a pit",
+			Edit: func(s *rules.State) { s.SetCell(s.Player, rules.Pit) },
+		},
+		{
+			Name: "portal on a wall",
+			Edit: func(s *rules.State) { s.Portals = [][2]rules.Pos{{{X: 3, Y: 1}, {X: 0, Y: 0}}} },
+		},
+		{
+			Name: "link without a plate",
+			Edit: func(s *rules.State) { s.Links = [][2]rules.Pos{{{X: 3, Y: 1}, {X: 2, Y: 1}}} },
+		},
+		{
+			Name: "link without a gate",
+			Edit: func(s *rules.State) {
+				s.SetCell(rules.Pos{X: 3, Y: 1}, rules.Plate)
+				s.Links = [][2]rules.Pos{{{X: 3, Y: 1}, {X: 2, Y: 1}}}
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			bad := newReplay(t, "#####\n#@$.#\n#####\n")
+			test.Edit(&bad.Start)
+			data, err := bad.MarshalBinary()
+			require.NoError(t, err)
+			require.ErrorIs(t, r.UnmarshalBinary(data), replay.ErrFormat)
+		})
+	}
+}
+
+func TestFinal(t *testing.T) {
+	r := newReplay(t, "#####\n#@$.#\n#####\n", rules.DirRight)
+	s, err := r.Final()
+	require.NoError(t, err)
+	require.True(t, s.IsWon())
+
+	r.Moves = append(r.Moves, rules.DirRight)
+	_, err = r.Final()
+	var moveErr *replay.MoveError
+	require.ErrorAs(t, err, &moveErr)
+	require.Equal(t, 1, moveErr.Index)
+
+	r.RulesVersion = rules.Version + 1
+	_, err = r.Final()
+	require.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package rules
+
+// Dir represents a direction.
+type Dir int
+
+const (
+	DirUp Dir = iota
+	DirRight
+	DirDown
+	DirLeft
+)
+
+// String returns a string representing the direction.
+func (d Dir) String() string {
+	switch d {
+	case DirUp:
+		return "Up"
+	case DirRight:
+		return "Right"
+	case DirDown:
+		return "Down"
+	case DirLeft:
+		return "Left"
+	}
+	panic("not reach")
+}
+
+// Vector returns a [-1, 1] value for each axis.
+func (d Dir) Vector() (x, y int) {
+	switch d {
+	case DirUp:
+		return 0, -1
+	case DirRight:
+		return 1, 0
+	case DirDown:
+		return 0, 1
+	case DirLeft:
+		return -1, 0
+	}
+	panic("not reach")
+}
+
+// Opposite returns the direction pointing the other way.
+func (d Dir) Opposite() Dir {
+	return (d + 2) % 4
+}
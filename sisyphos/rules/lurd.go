@@ -0,0 +1,103 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// LURD returns the letter of d in the LURD notation: the lowercase initial of
+// d.String() for a move, and the uppercase one for a push.
+func (d Dir) LURD(push bool) byte {
+	c := d.String()[0]
+	if push {
+		return c
+	}
+	return c - 'A' + 'a'
+}
+
+// dirOfLURD returns the direction of a LURD letter and whether it is a push.
+func dirOfLURD(c rune) (dir Dir, push bool, ok bool) {
+	for _, d := range []Dir{DirUp, DirRight, DirDown, DirLeft} {
+		switch c {
+		case rune(d.LURD(false)):
+			return d, false, true
+		case rune(d.LURD(true)):
+			return d, true, true
+		}
+	}
+	return 0, false, false
+}
+
+// StepError reports the first step of a solution that cannot be made.
+type StepError struct {
+	// Index is the 0-based index of the step, not counting whitespace.
+	Index int
+	Msg   string
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("rules: step %d: %s", e.Index+1, e.Msg)
+}
+
+// FormatLURD returns the moves made from s by dirs in the LURD notation.
+// FormatLURD returns a *StepError if a move is not possible.
+func FormatLURD(s State, dirs []Dir) (string, error) {
+	var b strings.Builder
+	for i, dir := range dirs {
+		next, result := s.Apply(dir)
+		if !result.Moved() {
+			return "", &StepError{Index: i, Msg: fmt.Sprintf("cannot move %s", dir)}
+		}
+		b.WriteByte(dir.LURD(result.Pushed))
+		s = next
+	}
+	return b.String(), nil
+}
+
+// ParseLURD returns the directions of a solution in the LURD notation.
+// Whitespace is ignored. ParseLURD returns a *StepError for an unknown letter.
+func ParseLURD(lurd string) ([]Dir, error) {
+	dirs := []Dir{}
+	for _, c := range lurd {
+		if unicode.IsSpace(c) {
+			continue
+		}
+		dir, _, ok := dirOfLURD(c)
+		if !ok {
+			return nil, &StepError{Index: len(dirs), Msg: fmt.Sprintf("unknown move %q", c)}
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// ValidateLURD replays a solution in the LURD notation from s and returns the
+// resulting State. Whitespace is ignored.
+//
+// ValidateLURD returns a *StepError for the first step that is not possible,
+// or whose case does not match whether it pushes a boulder.
+func ValidateLURD(s State, lurd string) (State, error) {
+	i := 0
+	for _, c := range lurd {
+		if unicode.IsSpace(c) {
+			continue
+		}
+		dir, push, ok := dirOfLURD(c)
+		if !ok {
+			return s, &StepError{Index: i, Msg: fmt.Sprintf("unknown move %q", c)}
+		}
+		next, result := s.Apply(dir)
+		switch {
+		case !result.Moved():
+			return s, &StepError{Index: i, Msg: fmt.Sprintf("cannot move %s", dir)}
+		case push && !result.Pushed:
+			return s, &StepError{Index: i, Msg: fmt.Sprintf("%c does not push a boulder", c)}
+		case !push && result.Pushed:
+			return s, &StepError{Index: i, Msg: fmt.Sprintf("%c pushes a boulder", c)}
+		}
+		s = next
+		i++
+	}
+	return s, nil
+}
@@ -0,0 +1,76 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+func TestLURD(t *testing.T) {
+	require.Equal(t, byte('u'), rules.DirUp.LURD(false))
+	require.Equal(t, byte('R'), rules.DirRight.LURD(true))
+	require.Equal(t, byte('d'), rules.DirDown.LURD(false))
+	require.Equal(t, byte('L'), rules.DirLeft.LURD(true))
+}
+
+func TestFormatLURD(t *testing.T) {
+	s := stateFromRows(
+		"@ ",
+		"$ ",
+		". ",
+	)
+	lurd, err := rules.FormatLURD(s, []rules.Dir{rules.DirRight, rules.DirLeft, rules.DirDown})
+	require.NoError(t, err)
+	require.Equal(t, "rlD", lurd)
+
+	_, err = rules.FormatLURD(s, []rules.Dir{rules.DirUp})
+	var stepErr *rules.StepError
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, 0, stepErr.Index)
+}
+
+func TestParseLURD(t *testing.T) {
+	dirs, err := rules.ParseLURD("rl\nD")
+	require.NoError(t, err)
+	require.Equal(t, []rules.Dir{rules.DirRight, rules.DirLeft, rules.DirDown}, dirs)
+
+	_, err = rules.ParseLURD("rlx")
+	var stepErr *rules.StepError
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, 2, stepErr.Index)
+}
+
+func TestValidateLURD(t *testing.T) {
+	s := stateFromRows(
+		"@ ",
+		"$ ",
+		". ",
+	)
+	testCases := []struct {
+		Name  string
+		LURD  string
+		Won   bool
+		Index int
+	}{
+		{Name: "solution", LURD: "rl D", Won: true, Index: -1},
+		{Name: "partial", LURD: "rl", Index: -1},
+		{Name: "blocked", LURD: "rlu", Index: 2},
+		{Name: "push as move", LURD: "rld", Index: 2},
+		{Name: "move as push", LURD: "Rl", Index: 0},
+		{Name: "unknown", LURD: "r?", Index: 1},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			end, err := rules.ValidateLURD(s, test.LURD)
+			if test.Index < 0 {
+				require.NoError(t, err)
+				require.Equal(t, test.Won, end.IsWon())
+				return
+			}
+			var stepErr *rules.StepError
+			require.ErrorAs(t, err, &stepErr)
+			require.Equal(t, test.Index, stepErr.Index)
+		})
+	}
+}
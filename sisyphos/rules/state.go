@@ -0,0 +1,641 @@
+// Package rules implements the sisyphos puzzle rules.
+//
+// The package is independent of ebiten and of any animation state,
+// so it can be used by solvers, servers and tests without a GPU context.
+package rules
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// Pos represents a cell position on the grid.
+type Pos struct {
+	X, Y int
+}
+
+// Add returns the position next to p in the given direction.
+func (p Pos) Add(dir Dir) Pos {
+	dx, dy := dir.Vector()
+	return Pos{p.X + dx, p.Y + dy}
+}
+
+// Cell represents the terrain of a single grid cell.
+type Cell int
+
+const (
+	Floor Cell = iota
+	Wall
+	// Ice does not stop a piece moving onto it, the piece slides on until it is blocked or leaves the ice.
+	Ice
+	// Pit cannot be entered by the player. A boulder pushed into a pit falls in
+	// and fills it, and the pit becomes Floor.
+	Pit
+	// OneWayUp, OneWayRight, OneWayDown and OneWayLeft can only be entered and
+	// left in their direction, by the player and boulders alike.
+	OneWayUp
+	OneWayRight
+	OneWayDown
+	OneWayLeft
+	// Plate opens the gates linked to it while the player or a boulder stands on it, see State.Links.
+	Plate
+	// Gate blocks pieces like a Wall while it is closed, see State.GateOpen.
+	Gate
+	// Key is picked up by the player walking onto it, and becomes Floor. Boulders cannot enter it.
+	Key
+	// Door can only be entered by the player holding a key, see State.Keys.
+	// The key is used up and the door becomes Floor. Boulders cannot enter it.
+	Door
+	// SlopeUp, SlopeRight, SlopeDown and SlopeLeft run downhill in their direction.
+	// A boulder left on a slope at the end of a turn rolls down, see State.Apply.
+	// The player walks on slopes like on Floor.
+	SlopeUp
+	SlopeRight
+	SlopeDown
+	SlopeLeft
+
+	// cellCount is the number of cell kinds, it stays last.
+	cellCount
+)
+
+// Valid returns true if c is a known cell kind.
+func (c Cell) Valid() bool {
+	return 0 <= c && c < cellCount
+}
+
+// OneWay returns the cell that can only be crossed in the given direction.
+func OneWay(dir Dir) Cell {
+	return OneWayUp + Cell(dir)
+}
+
+// OneWay returns the direction c can be crossed in, and true,
+// or false if c can be crossed in any direction.
+func (c Cell) OneWay() (Dir, bool) {
+	if c < OneWayUp || OneWayLeft < c {
+		return 0, false
+	}
+	return Dir(c - OneWayUp), true
+}
+
+// Slope returns the slope running downhill in the given direction.
+func Slope(dir Dir) Cell {
+	return SlopeUp + Cell(dir)
+}
+
+// Slope returns the downhill direction of c, and true, or false if c is not a slope.
+func (c Cell) Slope() (Dir, bool) {
+	if c < SlopeUp || SlopeLeft < c {
+		return 0, false
+	}
+	return Dir(c - SlopeUp), true
+}
+
+// allows returns true if a piece can move onto or off c in the given direction.
+func (c Cell) allows(dir Dir) bool {
+	d, ok := c.OneWay()
+	return !ok || d == dir
+}
+
+// Passable returns true if pieces can move onto c.
+// The player cannot enter a Pit though, see Pit, nothing enters a closed Gate,
+// and only the player enters a Key or Door, see Key.
+func (c Cell) Passable() bool {
+	return c != Wall
+}
+
+// EdgeMode controls what happens when a piece moves over the border of the grid.
+type EdgeMode int
+
+const (
+	// EdgeWalled blocks moves over the border.
+	EdgeWalled EdgeMode = iota
+	// EdgeToroidal wraps pieces around to the opposite border.
+	EdgeToroidal
+)
+
+// String returns a string representing the edge mode.
+func (e EdgeMode) String() string {
+	switch e {
+	case EdgeWalled:
+		return "Walled"
+	case EdgeToroidal:
+		return "Toroidal"
+	}
+	panic("not reach")
+}
+
+// State represents a snapshot of a puzzle.
+//
+// State is a value type. Apply never modifies the receiver, so states can be
+// kept around (e.g. by a solver) after further moves are applied.
+type State struct {
+	Width  int
+	Height int
+	// Grid holds the terrain in row-major order.
+	Grid     []Cell
+	Edge     EdgeMode
+	Player   Pos
+	Boulders []Pos
+	Targets  []Pos
+	// Portals holds the pairs of portal cells. A piece moving onto one portal of
+	// a pair comes out of the other one, keeping its direction, if the cell it comes
+	// out onto is free. Portals are Floor in Grid, and pieces never stop on them.
+	Portals [][2]Pos
+	// Links holds the pairs of linked Plate and Gate cells, in that order.
+	// A plate may open several gates, and a gate may be opened by several plates.
+	Links [][2]Pos
+	// Keys is the number of keys the player holds.
+	Keys int
+}
+
+// New creates an empty State of the given size.
+func New(width, height int) State {
+	return State{
+		Width:  width,
+		Height: height,
+		Grid:   make([]Cell, width*height),
+	}
+}
+
+// In returns true if p lies on the grid.
+func (s State) In(p Pos) bool {
+	return 0 <= p.X && p.X < s.Width && 0 <= p.Y && p.Y < s.Height
+}
+
+// CellAt returns the terrain at p.
+// Positions outside of the grid are reported as Wall.
+func (s State) CellAt(p Pos) Cell {
+	if !s.In(p) {
+		return Wall
+	}
+	return s.Grid[s.index(p)]
+}
+
+// SetCell sets the terrain at p.
+// SetCell modifies the grid in place and is meant for building states only.
+func (s State) SetCell(p Pos, c Cell) {
+	s.Grid[s.index(p)] = c
+}
+
+// Neighbor returns the cell next to p in the given direction according to the edge mode.
+// Neighbor returns false if the move would leave a walled grid.
+func (s State) Neighbor(p Pos, dir Dir) (Pos, bool) {
+	n := p.Add(dir)
+	if s.In(n) {
+		return n, true
+	}
+	switch s.Edge {
+	case EdgeWalled:
+		return n, false
+	case EdgeToroidal:
+		n.X = (n.X + s.Width) % s.Width
+		n.Y = (n.Y + s.Height) % s.Height
+		return n, true
+	}
+	panic("not reach")
+}
+
+// BoulderAt returns the index of the boulder at p, or -1 if there is none.
+func (s State) BoulderAt(p Pos) int {
+	for i, b := range s.Boulders {
+		if b == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsTarget returns true if there is a target at p.
+func (s State) IsTarget(p Pos) bool {
+	for _, t := range s.Targets {
+		if t == p {
+			return true
+		}
+	}
+	return false
+}
+
+// PortalAt returns the partner of the portal at p, and true, or false if there is no portal at p.
+func (s State) PortalAt(p Pos) (Pos, bool) {
+	for _, pair := range s.Portals {
+		switch p {
+		case pair[0]:
+			return pair[1], true
+		case pair[1]:
+			return pair[0], true
+		}
+	}
+	return Pos{}, false
+}
+
+// GateOpen returns true if the gate at p is open: while any plate linked to it
+// is pressed by the player or a boulder, and while a piece stands in the gate itself.
+// Gates close once they are left and no linked plate is pressed.
+func (s State) GateOpen(p Pos) bool {
+	if s.Occupied(p) {
+		return true
+	}
+	for _, l := range s.Links {
+		if l[1] == p && s.Occupied(l[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Occupied returns true if the player or a boulder stands at p.
+func (s State) Occupied(p Pos) bool {
+	return s.Player == p || s.BoulderAt(p) >= 0
+}
+
+// Hash returns a string identifying the part of s that Apply can change,
+// i.e. the pieces, the open pits, the keys and doors left, and the keys held.
+// Boulders are interchangeable, so states that differ only in the order
+// of their boulders share the same hash.
+func (s State) Hash() string {
+	cells := make([]int, 0, len(s.Boulders))
+	for _, b := range s.Boulders {
+		cells = append(cells, s.index(b))
+	}
+	slices.Sort(cells)
+	h := make([]byte, 0, 2*(len(cells)+1))
+	h = binary.LittleEndian.AppendUint16(h, uint16(s.index(s.Player)))
+	for _, c := range cells {
+		h = binary.LittleEndian.AppendUint16(h, uint16(c))
+	}
+	// The cells that Apply turns into Floor follow a separator, as the number of
+	// boulders varies when pits are filled.
+	h = binary.LittleEndian.AppendUint16(h, 0xffff)
+	for i, c := range s.Grid {
+		if c == Pit || c == Key || c == Door {
+			h = binary.LittleEndian.AppendUint16(h, uint16(i))
+		}
+	}
+	h = binary.LittleEndian.AppendUint16(h, 0xffff)
+	h = binary.LittleEndian.AppendUint16(h, uint16(s.Keys))
+	return string(h)
+}
+
+func (s State) index(p Pos) int {
+	return p.X + p.Y*s.Width
+}
+
+// Clone returns a deep copy of s.
+func (s State) Clone() State {
+	c := s
+	c.Grid = append([]Cell(nil), s.Grid...)
+	c.Boulders = append([]Pos(nil), s.Boulders...)
+	c.Targets = append([]Pos(nil), s.Targets...)
+	c.Portals = append([][2]Pos(nil), s.Portals...)
+	c.Links = append([][2]Pos(nil), s.Links...)
+	return c
+}
+
+// IsWon returns true if every target is covered by a boulder.
+func (s State) IsWon() bool {
+	for _, t := range s.Targets {
+		if s.BoulderAt(t) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// StateError reports a State that cannot come from a board, see Validate.
+type StateError struct {
+	Msg string
+}
+
+func (e *StateError) Error() string {
+	return "rules: invalid state: " + e.Msg
+}
+
+// Validate returns a *StateError if s holds pieces outside of the grid, on walls or pits,
+// or on the same cell, portals outside of the grid or on walls, or links that do not
+// join a plate to a gate.
+func (s State) Validate() error {
+	pieces := append([]Pos{s.Player}, s.Boulders...)
+	for i, p := range pieces {
+		switch {
+		case !s.In(p):
+			return &StateError{Msg: fmt.Sprintf("piece %d,%d outside of the board", p.X, p.Y)}
+		case s.CellAt(p) == Wall:
+			return &StateError{Msg: fmt.Sprintf("piece %d,%d on a wall", p.X, p.Y)}
+		case s.CellAt(p) == Pit:
+			return &StateError{Msg: fmt.Sprintf("piece %d,%d on a pit", p.X, p.Y)}
+		}
+		if slices.Contains(pieces[:i], p) {
+			return &StateError{Msg: fmt.Sprintf("two pieces at %d,%d", p.X, p.Y)}
+		}
+	}
+	for _, pair := range s.Portals {
+		for _, p := range pair {
+			if !s.In(p) {
+				return &StateError{Msg: fmt.Sprintf("portal %d,%d outside of the board", p.X, p.Y)}
+			}
+			if s.CellAt(p) == Wall {
+				return &StateError{Msg: fmt.Sprintf("portal %d,%d on a wall", p.X, p.Y)}
+			}
+		}
+		if pair[0] == pair[1] {
+			return &StateError{Msg: fmt.Sprintf("portal %d,%d paired with itself", pair[0].X, pair[0].Y)}
+		}
+	}
+	for _, link := range s.Links {
+		if !s.In(link[0]) || s.CellAt(link[0]) != Plate {
+			return &StateError{Msg: fmt.Sprintf("link %d,%d not on a plate", link[0].X, link[0].Y)}
+		}
+		if !s.In(link[1]) || s.CellAt(link[1]) != Gate {
+			return &StateError{Msg: fmt.Sprintf("link %d,%d not on a gate", link[1].X, link[1].Y)}
+		}
+	}
+	return nil
+}
+
+// Move describes a single piece moving from one cell to another.
+type Move struct {
+	From, To Pos
+	// Dir is the direction of the move. To is not next to From when the move wraps around.
+	Dir Dir
+	// Dist is the number of cells travelled, more than one when sliding on ice.
+	Dist int
+	// Boulder is true if the moving piece is a boulder, and false if it is the player.
+	Boulder bool
+	// Fill is true if the boulder fell into the pit at To and filled it.
+	Fill bool
+	// Unfill is true if the boulder comes back out of the pit at From, taking back a Fill.
+	Unfill bool
+	// Pickup is true if the player picked up the key at To, and Drop is true
+	// if the player puts the key back at From, taking back a Pickup.
+	Pickup, Drop bool
+	// Unlock is true if the player used a key on the door at To, and Lock is true
+	// if the door closes again at From, taking back an Unlock.
+	Unlock, Lock bool
+	// Portal is true if the piece went through a portal: it travelled ViaDist cells
+	// onto the portal at Via[0], and came out of its partner at Via[1].
+	Portal  bool
+	Via     [2]Pos
+	ViaDist int
+}
+
+// MoveResult describes the effects of applying a direction to a State.
+type MoveResult struct {
+	Moves []Move
+	// Rolls holds the boulders rolling down slopes after Moves, one after another.
+	// A boulder rolling round a bend takes a move for each straight part.
+	Rolls  []Move
+	Pushed bool
+}
+
+// Moved returns true if any piece moved.
+func (r MoveResult) Moved() bool {
+	return len(r.Moves) > 0
+}
+
+// Phases returns the moves in the order they are made: Moves all at once, then the Rolls one by one.
+// The phases of a reversed result are made in the opposite order, last one first.
+func (r MoveResult) Phases() [][]Move {
+	phases := [][]Move{r.Moves}
+	for _, m := range r.Rolls {
+		phases = append(phases, []Move{m})
+	}
+	return phases
+}
+
+// Reverse returns the result of taking back r, i.e. every piece moving back the way it came.
+func (r MoveResult) Reverse() MoveResult {
+	result := MoveResult{Moves: reverseMoves(r.Moves), Pushed: r.Pushed}
+	if r.Rolls != nil {
+		result.Rolls = reverseMoves(r.Rolls)
+	}
+	return result
+}
+
+func reverseMoves(ms []Move) []Move {
+	moves := make([]Move, len(ms))
+	for i, m := range ms {
+		moves[i] = Move{
+			From: m.To, To: m.From, Dir: m.Dir.Opposite(), Dist: m.Dist, Boulder: m.Boulder,
+			Fill: m.Unfill, Unfill: m.Fill, Pickup: m.Drop, Drop: m.Pickup, Unlock: m.Lock, Lock: m.Unlock,
+		}
+		if m.Portal {
+			moves[i].Portal = true
+			moves[i].Via = [2]Pos{m.Via[1], m.Via[0]}
+			moves[i].ViaDist = m.Dist - m.ViaDist
+		}
+	}
+	return moves
+}
+
+// Apply moves the player in the given direction, pushing a boulder if needed.
+// A piece moving onto ice slides on, see Ice, and a boulder moving into a pit fills it, see Pit.
+// Pieces cross one-way cells in their direction only, see OneWayUp, and go through portals, see Portals.
+// Whether a gate lets a piece in depends on where the pieces stand before that piece moves, see GateOpen.
+// The player picks up keys and opens doors, see Key and Door.
+// At the end of the turn, the boulders left on slopes roll downhill until they
+// reach other ground or are blocked, e.g. wedged by the player, see Rolls.
+// Apply returns the resulting State, or s itself if the move is not possible.
+func (s State) Apply(dir Dir) (State, MoveResult) {
+	if !s.CellAt(s.Player).allows(dir) {
+		return s, MoveResult{}
+	}
+	player, ok := s.step(Move{From: s.Player, To: s.Player, Dir: dir}, false)
+	if !ok {
+		return s, MoveResult{}
+	}
+	i := s.BoulderAt(player.To)
+	if i < 0 {
+		s.Player = player.To
+		player = s.slide(player, false)
+		s.Player = player.To
+		s, player = s.useKeys(player)
+		s, rolls := s.roll()
+		return s, MoveResult{Moves: []Move{player}, Rolls: rolls}
+	}
+	if player.Portal {
+		// The player only comes out of a portal onto a free cell.
+		return s, MoveResult{}
+	}
+	// The player entered the boulder's cell in dir, so a one-way cell there lets the boulder leave.
+	boulder, ok := s.step(Move{From: player.To, To: player.To, Dir: dir, Boulder: true}, true)
+	if !ok || s.Occupied(boulder.To) {
+		return s, MoveResult{}
+	}
+	s.Player = player.To
+	s.Boulders = append([]Pos(nil), s.Boulders...)
+	s.Boulders[i] = boulder.To
+	boulder = s.slide(boulder, true)
+	if s.CellAt(boulder.To) == Pit {
+		s.Grid = append([]Cell(nil), s.Grid...)
+		s.SetCell(boulder.To, Floor)
+		s.Boulders = slices.Delete(s.Boulders, i, i+1)
+		boulder.Fill = true
+	} else {
+		s.Boulders[i] = boulder.To
+	}
+	// The player follows the boulder if it stands on ice.
+	player = s.slide(player, false)
+	s.Player = player.To
+	s, player = s.useKeys(player)
+	s, rolls := s.roll()
+	return s, MoveResult{
+		Moves:  []Move{player, boulder},
+		Rolls:  rolls,
+		Pushed: true,
+	}
+}
+
+// canEnter returns true if the terrain at p lets the player, or a boulder, move onto it in dir.
+func (s State) canEnter(p Pos, dir Dir, boulder bool) bool {
+	switch c := s.CellAt(p); c {
+	case Wall:
+		return false
+	case Pit:
+		return boulder
+	case Gate:
+		return s.GateOpen(p)
+	case Key:
+		return !boulder
+	case Door:
+		return !boulder && 0 < s.Keys
+	default:
+		return c.allows(dir)
+	}
+}
+
+// useKeys picks up the key, or uses a key on the door, where the player ends m.
+func (s State) useKeys(m Move) (State, Move) {
+	switch s.CellAt(m.To) {
+	case Key:
+		s.Keys++
+		m.Pickup = true
+	case Door:
+		s.Keys--
+		m.Unlock = true
+	default:
+		return s, m
+	}
+	s.Grid = append([]Cell(nil), s.Grid...)
+	s.SetCell(m.To, Floor)
+	return s, m
+}
+
+// step moves m on by one cell, through a portal if there is one, and returns
+// false if the terrain blocks the way. The cell reached may still be occupied.
+// A move goes through one portal at most, and cannot come out onto another portal.
+func (s State) step(m Move, boulder bool) (Move, bool) {
+	n, ok := s.Neighbor(m.To, m.Dir)
+	if !ok || !s.canEnter(n, m.Dir, boulder) {
+		return m, false
+	}
+	partner, portal := s.PortalAt(n)
+	if !portal {
+		m.To = n
+		m.Dist++
+		return m, true
+	}
+	if m.Portal {
+		return m, false
+	}
+	exit, ok := s.Neighbor(partner, m.Dir)
+	if _, onPortal := s.PortalAt(exit); !ok || onPortal || !s.canEnter(exit, m.Dir, boulder) {
+		return m, false
+	}
+	m.Portal = true
+	m.Via = [2]Pos{n, partner}
+	m.ViaDist = m.Dist + 1
+	m.To = exit
+	m.Dist += 2
+	return m, true
+}
+
+// slide moves m on over ice until the piece is blocked or leaves the ice.
+// A piece going round a toroidal board stops before where it started.
+// A sliding boulder stops in a pit.
+func (s State) slide(m Move, boulder bool) Move {
+	for s.CellAt(m.To) == Ice {
+		next, ok := s.step(m, boulder)
+		if !ok || next.To == m.From || s.Occupied(next.To) {
+			break
+		}
+		m = next
+	}
+	return m
+}
+
+// roll lets the boulders on slopes roll downhill. A rolling boulder follows the
+// slopes round bends, slides on over ice and fills a pit it ends in.
+// The boulders roll one after another until none can roll any more, so a boulder
+// stopped by another one rolls on once that one made way for it. A boulder never
+// rolls onto the same cell twice a turn, which keeps it from rolling round loops
+// of slopes for ever.
+func (s State) roll() (State, []Move) {
+	var rolls []Move
+	// visited holds the cells each boulder was on this turn, in the order of s.Boulders.
+	visited := make([]map[Pos]bool, len(s.Boulders))
+	for {
+		i := -1
+		for j, b := range s.Boulders {
+			if next, ok := s.rollTo(b); ok && !visited[j][next] {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return s, rolls
+		}
+		if len(rolls) == 0 {
+			s.Boulders = append([]Pos(nil), s.Boulders...)
+		}
+		if visited[i] == nil {
+			visited[i] = map[Pos]bool{s.Boulders[i]: true}
+		}
+		m := Move{From: s.Boulders[i], To: s.Boulders[i], Boulder: true}
+		for {
+			dir, ok := s.CellAt(m.To).Slope()
+			if !ok {
+				break
+			}
+			part := m
+			if dir != m.Dir && m.To != m.From {
+				// Round the bend.
+				part = Move{From: m.To, To: m.To, Boulder: true}
+			}
+			part.Dir = dir
+			next, ok := s.step(part, true)
+			if !ok || s.Occupied(next.To) || visited[i][next.To] {
+				break
+			}
+			if part.From != m.From {
+				rolls = append(rolls, m)
+			}
+			visited[i][next.To] = true
+			s.Boulders[i] = next.To
+			m = next
+		}
+		m = s.slide(m, true)
+		if s.CellAt(m.To) == Pit {
+			s.Grid = append([]Cell(nil), s.Grid...)
+			s.SetCell(m.To, Floor)
+			s.Boulders = slices.Delete(s.Boulders, i, i+1)
+			visited = slices.Delete(visited, i, i+1)
+			m.Fill = true
+		} else {
+			s.Boulders[i] = m.To
+			visited[i][m.To] = true
+		}
+		rolls = append(rolls, m)
+	}
+}
+
+// rollTo returns the cell the boulder at p rolls onto first, and true, or false
+// if p is no slope or something stops the boulder from rolling down.
+func (s State) rollTo(p Pos) (Pos, bool) {
+	dir, ok := s.CellAt(p).Slope()
+	if !ok {
+		return Pos{}, false
+	}
+	next, ok := s.step(Move{From: p, To: p, Dir: dir}, true)
+	return next.To, ok && !s.Occupied(next.To)
+}
@@ -0,0 +1,598 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+// stateFromRows builds a State from rows using '#' for walls, '@' for the player,
+// '$' for boulders and '.' for targets. '*' and '+' put a boulder or the player on a target.
+// '~' is ice, '&' a boulder on ice, 'o' a pit and '^', '>', 'v', '<' one-way cells.
+func stateFromRows(rows ...string) rules.State {
+	s := rules.New(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			p := rules.Pos{X: x, Y: y}
+			switch c {
+			case '#':
+				s.SetCell(p, rules.Wall)
+			case '@':
+				s.Player = p
+			case '$':
+				s.Boulders = append(s.Boulders, p)
+			case '.':
+				s.Targets = append(s.Targets, p)
+			case '*':
+				s.Boulders = append(s.Boulders, p)
+				s.Targets = append(s.Targets, p)
+			case '+':
+				s.Player = p
+				s.Targets = append(s.Targets, p)
+			case '~':
+				s.SetCell(p, rules.Ice)
+			case '&':
+				s.SetCell(p, rules.Ice)
+				s.Boulders = append(s.Boulders, p)
+			case 'o':
+				s.SetCell(p, rules.Pit)
+			case '^':
+				s.SetCell(p, rules.OneWayUp)
+			case '>':
+				s.SetCell(p, rules.OneWayRight)
+			case 'v':
+				s.SetCell(p, rules.OneWayDown)
+			case '<':
+				s.SetCell(p, rules.OneWayLeft)
+			case '_':
+				s.SetCell(p, rules.Plate)
+			case '|':
+				s.SetCell(p, rules.Gate)
+			case 'k':
+				s.SetCell(p, rules.Key)
+			case 'd':
+				s.SetCell(p, rules.Door)
+			case 'U':
+				s.SetCell(p, rules.SlopeUp)
+			case 'R':
+				s.SetCell(p, rules.SlopeRight)
+			case 'D':
+				s.SetCell(p, rules.SlopeDown)
+			case 'L':
+				s.SetCell(p, rules.SlopeLeft)
+			}
+		}
+	}
+	return s
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Dir   rules.Dir
+		Edge  rules.EdgeMode
+		Input []string
+		Want  []string
+		Moved bool
+	}{
+		{
+			Name:  "walk",
+			Dir:   rules.DirRight,
+			Input: []string{"@  "},
+			Want:  []string{" @ "},
+			Moved: true,
+		},
+		{
+			Name:  "wall",
+			Dir:   rules.DirDown,
+			Input: []string{"@", "#"},
+			Want:  []string{"@", "#"},
+		},
+		{
+			Name:  "push",
+			Dir:   rules.DirRight,
+			Input: []string{"@$ "},
+			Want:  []string{" @$"},
+			Moved: true,
+		},
+		{
+			Name:  "push onto target",
+			Dir:   rules.DirLeft,
+			Input: []string{".$@"},
+			Want:  []string{"*@ "},
+			Moved: true,
+		},
+		{
+			Name:  "push into wall",
+			Dir:   rules.DirUp,
+			Input: []string{"#", "$", "@"},
+			Want:  []string{"#", "$", "@"},
+		},
+		{
+			Name:  "push into boulder",
+			Dir:   rules.DirRight,
+			Input: []string{"@$$ "},
+			Want:  []string{"@$$ "},
+		},
+		{
+			Name:  "push off target",
+			Dir:   rules.DirRight,
+			Input: []string{"@* ."},
+			Want:  []string{" +$."},
+			Moved: true,
+		},
+		{
+			Name:  "walled edge",
+			Dir:   rules.DirLeft,
+			Input: []string{"@  "},
+			Want:  []string{"@  "},
+		},
+		{
+			Name:  "walled edge push",
+			Dir:   rules.DirRight,
+			Input: []string{" @$"},
+			Want:  []string{" @$"},
+		},
+		{
+			Name:  "toroidal walk",
+			Dir:   rules.DirLeft,
+			Edge:  rules.EdgeToroidal,
+			Input: []string{"@  "},
+			Want:  []string{"  @"},
+			Moved: true,
+		},
+		{
+			Name:  "toroidal push",
+			Dir:   rules.DirDown,
+			Edge:  rules.EdgeToroidal,
+			Input: []string{" ", "@", "$"},
+			Want:  []string{"$", " ", "@"},
+			Moved: true,
+		},
+		{
+			Name:  "toroidal push into player",
+			Dir:   rules.DirRight,
+			Edge:  rules.EdgeToroidal,
+			Input: []string{"@$"},
+			Want:  []string{"@$"},
+		},
+		{
+			Name:  "toroidal wall",
+			Dir:   rules.DirUp,
+			Edge:  rules.EdgeToroidal,
+			Input: []string{"@", " ", "#"},
+			Want:  []string{"@", " ", "#"},
+		},
+		{
+			Name:  "slide",
+			Dir:   rules.DirRight,
+			Input: []string{"@~~ #"},
+			Want:  []string{"   @#"},
+			Moved: true,
+		},
+		{
+			Name:  "slide into wall",
+			Dir:   rules.DirRight,
+			Input: []string{"@~~#"},
+			Want:  []string{"  @#"},
+			Moved: true,
+		},
+		{
+			Name:  "push onto ice",
+			Dir:   rules.DirRight,
+			Input: []string{"@$~~ "},
+			Want:  []string{" @  $"},
+			Moved: true,
+		},
+		{
+			Name:  "push onto ice into boulder",
+			Dir:   rules.DirRight,
+			Input: []string{"@$~~$"},
+			Want:  []string{" @ $$"},
+			Moved: true,
+		},
+		{
+			Name:  "push on ice",
+			Dir:   rules.DirRight,
+			Input: []string{"@&~ #"},
+			Want:  []string{"  @$#"},
+			Moved: true,
+		},
+		{
+			Name:  "toroidal slide",
+			Dir:   rules.DirRight,
+			Edge:  rules.EdgeToroidal,
+			Input: []string{"@~~"},
+			Want:  []string{"  @"},
+			Moved: true,
+		},
+		{
+			Name:  "walk into pit",
+			Dir:   rules.DirRight,
+			Input: []string{"@o"},
+			Want:  []string{"@o"},
+		},
+		{
+			Name:  "slide up to pit",
+			Dir:   rules.DirRight,
+			Input: []string{"@~o"},
+			Want:  []string{" @o"},
+			Moved: true,
+		},
+		{
+			Name:  "cross one-way",
+			Dir:   rules.DirRight,
+			Input: []string{"@> "},
+			Want:  []string{" @ "},
+			Moved: true,
+		},
+		{
+			Name:  "enter one-way against its direction",
+			Dir:   rules.DirRight,
+			Input: []string{"@< "},
+			Want:  []string{"@  "},
+		},
+		{
+			Name:  "push onto one-way",
+			Dir:   rules.DirRight,
+			Input: []string{"@$> "},
+			Want:  []string{" @$ "},
+			Moved: true,
+		},
+		{
+			Name:  "push against one-way",
+			Dir:   rules.DirLeft,
+			Input: []string{" >$@"},
+			Want:  []string{" >$@"},
+		},
+		{
+			Name:  "slide up to one-way",
+			Dir:   rules.DirRight,
+			Input: []string{"@~^ "},
+			Want:  []string{" @^ "},
+			Moved: true,
+		},
+		{
+			Name:  "walk onto target",
+			Dir:   rules.DirUp,
+			Input: []string{".", "@"},
+			Want:  []string{"+", " "},
+			Moved: true,
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			input := stateFromRows(test.Input...)
+			input.Edge = test.Edge
+			before := input.Clone()
+			got, result := input.Apply(test.Dir)
+			require.Equal(t, test.Moved, result.Moved())
+			want := stateFromRows(test.Want...)
+			require.Equal(t, want.Player, got.Player)
+			require.Equal(t, want.Boulders, got.Boulders)
+			// Apply must not modify its receiver.
+			require.Equal(t, before, input)
+		})
+	}
+}
+
+func TestApplyResult(t *testing.T) {
+	s := stateFromRows("@$ ")
+	_, result := s.Apply(rules.DirRight)
+	require.True(t, result.Pushed)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirRight, Dist: 1},
+		{From: rules.Pos{X: 1, Y: 0}, To: rules.Pos{X: 2, Y: 0}, Dir: rules.DirRight, Dist: 1, Boulder: true},
+	}, result.Moves)
+}
+
+func TestApplyWrapResult(t *testing.T) {
+	s := stateFromRows("$ @")
+	s.Edge = rules.EdgeToroidal
+	_, result := s.Apply(rules.DirRight)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 2, Y: 0}, To: rules.Pos{X: 0, Y: 0}, Dir: rules.DirRight, Dist: 1},
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirRight, Dist: 1, Boulder: true},
+	}, result.Moves)
+}
+
+func TestReverse(t *testing.T) {
+	s := stateFromRows("@$ ")
+	_, result := s.Apply(rules.DirRight)
+	require.Equal(t, rules.MoveResult{
+		Moves: []rules.Move{
+			{From: rules.Pos{X: 1, Y: 0}, To: rules.Pos{X: 0, Y: 0}, Dir: rules.DirLeft, Dist: 1},
+			{From: rules.Pos{X: 2, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirLeft, Dist: 1, Boulder: true},
+		},
+		Pushed: true,
+	}, result.Reverse())
+}
+
+func TestApplySlideResult(t *testing.T) {
+	s := stateFromRows("@&~ #")
+	_, result := s.Apply(rules.DirRight)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 2, Y: 0}, Dir: rules.DirRight, Dist: 2},
+		{From: rules.Pos{X: 1, Y: 0}, To: rules.Pos{X: 3, Y: 0}, Dir: rules.DirRight, Dist: 2, Boulder: true},
+	}, result.Moves)
+}
+
+func TestApplyPit(t *testing.T) {
+	s := stateFromRows("@$o$.")
+	before := s.Clone()
+	filled, result := s.Apply(rules.DirRight)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirRight, Dist: 1},
+		{From: rules.Pos{X: 1, Y: 0}, To: rules.Pos{X: 2, Y: 0}, Dir: rules.DirRight, Dist: 1, Boulder: true, Fill: true},
+	}, result.Moves)
+	require.Equal(t, rules.Floor, filled.CellAt(rules.Pos{X: 2, Y: 0}))
+	require.Equal(t, []rules.Pos{{X: 3, Y: 0}}, filled.Boulders)
+	require.Equal(t, before, s)
+	require.True(t, result.Reverse().Moves[1].Unfill)
+
+	// The filled pit is floor to walk and push over.
+	won, result := filled.Apply(rules.DirRight)
+	require.True(t, result.Moved())
+	won, result = won.Apply(rules.DirRight)
+	require.True(t, result.Pushed)
+	require.True(t, won.IsWon())
+
+	// A boulder sliding over ice falls into a pit too.
+	slid, result := stateFromRows("@$~o").Apply(rules.DirRight)
+	require.True(t, result.Moves[1].Fill)
+	require.Equal(t, rules.Pos{X: 3, Y: 0}, result.Moves[1].To)
+	require.Empty(t, slid.Boulders)
+}
+
+func TestApplyOneWay(t *testing.T) {
+	s := stateFromRows("@ ", "  ")
+	s.SetCell(s.Player, rules.OneWayRight)
+	_, result := s.Apply(rules.DirDown)
+	require.False(t, result.Moved())
+	_, result = s.Apply(rules.DirRight)
+	require.True(t, result.Moved())
+
+	require.Equal(t, rules.OneWayLeft, rules.OneWay(rules.DirLeft))
+	dir, ok := rules.OneWayLeft.OneWay()
+	require.True(t, ok)
+	require.Equal(t, rules.DirLeft, dir)
+	_, ok = rules.Ice.OneWay()
+	require.False(t, ok)
+}
+
+func TestApplyPortal(t *testing.T) {
+	// The portals are at x=2 and x=4.
+	s := stateFromRows("@$    ")
+	s.Portals = [][2]rules.Pos{{{X: 2, Y: 0}, {X: 4, Y: 0}}}
+
+	pushed, result := s.Apply(rules.DirRight)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirRight, Dist: 1},
+		{
+			From: rules.Pos{X: 1, Y: 0}, To: rules.Pos{X: 5, Y: 0}, Dir: rules.DirRight, Dist: 2, Boulder: true,
+			Portal: true, Via: [2]rules.Pos{{X: 2, Y: 0}, {X: 4, Y: 0}}, ViaDist: 1,
+		},
+	}, result.Moves)
+	require.Equal(t, []rules.Pos{{X: 5, Y: 0}}, pushed.Boulders)
+	require.Equal(t, rules.Move{
+		From: rules.Pos{X: 5, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirLeft, Dist: 2, Boulder: true,
+		Portal: true, Via: [2]rules.Pos{{X: 4, Y: 0}, {X: 2, Y: 0}}, ViaDist: 1,
+	}, result.Reverse().Moves[1])
+
+	// The player would come out pushing the boulder off the board.
+	_, result = pushed.Apply(rules.DirRight)
+	require.False(t, result.Moved())
+
+	// The player does not come out onto a boulder, even if it could be pushed on.
+	blocked := stateFromRows("@    ")
+	blocked.Portals = [][2]rules.Pos{{{X: 1, Y: 0}, {X: 2, Y: 0}}}
+	blocked.Boulders = []rules.Pos{{X: 3, Y: 0}}
+	_, result = blocked.Apply(rules.DirRight)
+	require.False(t, result.Moved())
+
+	// Going back, the player comes out of the first portal.
+	back := pushed
+	back.Player = rules.Pos{X: 5, Y: 0}
+	back.Boulders = nil
+	back, result = back.Apply(rules.DirLeft)
+	require.True(t, result.Moves[0].Portal)
+	require.Equal(t, rules.Pos{X: 1, Y: 0}, back.Player)
+}
+
+func TestApplyGate(t *testing.T) {
+	s := stateFromRows("@_| ")
+	s.Links = [][2]rules.Pos{{{X: 1, Y: 0}, {X: 2, Y: 0}}}
+	gate := rules.Pos{X: 2, Y: 0}
+	require.False(t, s.GateOpen(gate))
+
+	// The player opens the gate from the plate, and it stays open while the player is in it.
+	s, _ = s.Apply(rules.DirRight)
+	require.True(t, s.GateOpen(gate))
+	s, result := s.Apply(rules.DirRight)
+	require.True(t, result.Moved())
+	require.True(t, s.GateOpen(gate))
+	s, _ = s.Apply(rules.DirRight)
+	require.False(t, s.GateOpen(gate))
+	_, result = s.Apply(rules.DirLeft)
+	require.False(t, result.Moved())
+
+	// An unlinked gate never opens.
+	_, result = stateFromRows("@$|").Apply(rules.DirRight)
+	require.False(t, result.Moved())
+
+	// A boulder on the plate opens the gate, and may be pushed on into it.
+	s = stateFromRows("@$_| ")
+	s.Links = [][2]rules.Pos{{{X: 2, Y: 0}, {X: 3, Y: 0}}}
+	s, _ = s.Apply(rules.DirRight)
+	require.True(t, s.GateOpen(rules.Pos{X: 3, Y: 0}))
+	s, result = s.Apply(rules.DirRight)
+	require.True(t, result.Pushed)
+	require.Equal(t, []rules.Pos{{X: 3, Y: 0}}, s.Boulders)
+	require.True(t, s.GateOpen(rules.Pos{X: 3, Y: 0}))
+}
+
+func TestApplyKey(t *testing.T) {
+	// The door is locked without a key.
+	_, result := stateFromRows("@dk").Apply(rules.DirRight)
+	require.False(t, result.Moved())
+
+	s := stateFromRows("@k d")
+	before := s.Clone()
+	s, result = s.Apply(rules.DirRight)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirRight, Dist: 1, Pickup: true},
+	}, result.Moves)
+	require.Equal(t, 1, s.Keys)
+	require.Equal(t, rules.Floor, s.CellAt(rules.Pos{X: 1, Y: 0}))
+	require.Equal(t, rules.Key, before.CellAt(rules.Pos{X: 1, Y: 0}))
+	require.True(t, result.Reverse().Moves[0].Drop)
+
+	s, _ = s.Apply(rules.DirRight)
+	s, result = s.Apply(rules.DirRight)
+	require.True(t, result.Moves[0].Unlock)
+	require.True(t, result.Reverse().Moves[0].Lock)
+	require.Equal(t, 0, s.Keys)
+	require.Equal(t, rules.Floor, s.CellAt(rules.Pos{X: 3, Y: 0}))
+
+	// Boulders neither pick up keys nor open doors.
+	_, result = stateFromRows("@$k").Apply(rules.DirRight)
+	require.False(t, result.Moved())
+	s = stateFromRows("@$d")
+	s.Keys = 1
+	_, result = s.Apply(rules.DirRight)
+	require.False(t, result.Moved())
+}
+
+func TestApplySlope(t *testing.T) {
+	// The player wedges the boulder pushed uphill, and pushes it over the crest.
+	s := stateFromRows("    ", "@$L ")
+	s, result := s.Apply(rules.DirRight)
+	require.Empty(t, result.Rolls)
+	require.Equal(t, []rules.Pos{{X: 2, Y: 1}}, s.Boulders)
+	crest, result := s.Apply(rules.DirRight)
+	require.Empty(t, result.Rolls)
+	require.Equal(t, []rules.Pos{{X: 3, Y: 1}}, crest.Boulders)
+
+	// Stepping aside, the player lets the boulder roll back.
+	s, result = s.Apply(rules.DirUp)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 2, Y: 1}, To: rules.Pos{X: 1, Y: 1}, Dir: rules.DirLeft, Dist: 1, Boulder: true},
+	}, result.Rolls)
+	require.Equal(t, []rules.Pos{{X: 1, Y: 1}}, s.Boulders)
+
+	// The boulder rolls down to the foot of the slope.
+	s, result = stateFromRows(" LL$@").Apply(rules.DirLeft)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 2, Y: 0}, To: rules.Pos{X: 0, Y: 0}, Dir: rules.DirLeft, Dist: 2, Boulder: true},
+	}, result.Rolls)
+	require.Equal(t, [][]rules.Move{result.Moves, result.Rolls}, result.Phases())
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 2, Y: 0}, Dir: rules.DirRight, Dist: 2, Boulder: true},
+	}, result.Reverse().Rolls)
+	require.Equal(t, []rules.Pos{{X: 0, Y: 0}}, s.Boulders)
+
+	// A boulder rolling round a bend takes a roll for each straight part.
+	_, result = stateFromRows(" @", " $", "DL", "  ").Apply(rules.DirDown)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 1, Y: 2}, To: rules.Pos{X: 0, Y: 2}, Dir: rules.DirLeft, Dist: 1, Boulder: true},
+		{From: rules.Pos{X: 0, Y: 2}, To: rules.Pos{X: 0, Y: 3}, Dir: rules.DirDown, Dist: 1, Boulder: true},
+	}, result.Rolls)
+
+	// A boulder rolling into a pit fills it.
+	s, result = stateFromRows("oL$@").Apply(rules.DirLeft)
+	require.True(t, result.Rolls[0].Fill)
+	require.Empty(t, s.Boulders)
+	require.Equal(t, rules.Floor, s.CellAt(rules.Pos{X: 0, Y: 0}))
+
+	// A boulder stops before it comes round a loop of slopes.
+	s, result = stateFromRows("RD  ", "UL$@").Apply(rules.DirLeft)
+	require.Len(t, result.Rolls, 3)
+	require.Equal(t, []rules.Pos{{X: 1, Y: 0}}, s.Boulders)
+
+	// A boulder stopped by another one rolls on once that one rolled away.
+	s = stateFromRows("RRD  ", "    @")
+	s.Boulders = []rules.Pos{{X: 0, Y: 0}, {X: 2, Y: 0}}
+	s, result = s.Apply(rules.DirUp)
+	require.Equal(t, []rules.Move{
+		{From: rules.Pos{X: 0, Y: 0}, To: rules.Pos{X: 1, Y: 0}, Dir: rules.DirRight, Dist: 1, Boulder: true},
+		{From: rules.Pos{X: 2, Y: 0}, To: rules.Pos{X: 2, Y: 1}, Dir: rules.DirDown, Dist: 1, Boulder: true},
+		{From: rules.Pos{X: 1, Y: 0}, To: rules.Pos{X: 2, Y: 0}, Dir: rules.DirRight, Dist: 1, Boulder: true},
+	}, result.Rolls)
+	require.Equal(t, []rules.Pos{{X: 2, Y: 0}, {X: 2, Y: 1}}, s.Boulders)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, stateFromRows("@$_|.").Validate())
+
+	testCases := []struct {
+		Name string
+		Edit func(s *rules.State)
+	}{
+		{
+			Name: "player outside of the board",
+			Edit: func(s *rules.State) { s.Player = rules.Pos{X: 5, Y: 0} },
+		},
+		{
+			Name: "boulder on the player",
+			Edit: func(s *rules.State) { s.Boulders[0] = s.Player },
+		},
+		{
+			Name: "boulder on a wall",
+			Edit: func(s *rules.State) { s.SetCell(s.Boulders[0], rules.Wall) },
+		},
+		{
+			Name: "player on a pit",
+			Edit: func(s *rules.State) { s.SetCell(s.Player, rules.Pit) },
+		},
+		{
+			Name: "portal paired with itself",
+			Edit: func(s *rules.State) { s.Portals = [][2]rules.Pos{{{X: 4, Y: 0}, {X: 4, Y: 0}}} },
+		},
+		{
+			Name: "portal outside of the board",
+			Edit: func(s *rules.State) { s.Portals = [][2]rules.Pos{{{X: 4, Y: 0}, {X: 4, Y: 1}}} },
+		},
+		{
+			Name: "link from a gate",
+			Edit: func(s *rules.State) { s.Links = [][2]rules.Pos{{{X: 3, Y: 0}, {X: 3, Y: 0}}} },
+		},
+		{
+			Name: "link to a plate",
+			Edit: func(s *rules.State) { s.Links = [][2]rules.Pos{{{X: 2, Y: 0}, {X: 2, Y: 0}}} },
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			s := stateFromRows("@$_|.")
+			test.Edit(&s)
+			var stateErr *rules.StateError
+			require.ErrorAs(t, s.Validate(), &stateErr)
+		})
+	}
+}
+
+func TestIsWon(t *testing.T) {
+	require.False(t, stateFromRows("@$.").IsWon())
+	require.True(t, stateFromRows("@ *").IsWon())
+	require.False(t, stateFromRows(" $+").IsWon())
+	require.False(t, stateFromRows("@*.$").IsWon())
+	require.True(t, stateFromRows("@**$").IsWon())
+}
+
+func TestHash(t *testing.T) {
+	a := stateFromRows("@$ $")
+	b := a.Clone()
+	b.Boulders[0], b.Boulders[1] = b.Boulders[1], b.Boulders[0]
+	require.Equal(t, a.Hash(), b.Hash())
+	c, _ := a.Apply(rules.DirRight)
+	require.NotEqual(t, a.Hash(), c.Hash())
+
+	// Filling one pit or the other leaves the same pieces, but not the same board.
+	require.NotEqual(t, stateFromRows("o@ ").Hash(), stateFromRows(" @o").Hash())
+
+	// Holding a key is not the same as leaving it on the board.
+	held := stateFromRows(" @ ")
+	held.Keys = 1
+	require.NotEqual(t, held.Hash(), stateFromRows(" @ ").Hash())
+}
@@ -0,0 +1,6 @@
+package rules
+
+// Version identifies the rules. It is increased whenever the same moves may
+// lead to a different State, so that recorded games can tell whether they
+// still play back the same.
+const Version = 1
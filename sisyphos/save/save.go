@@ -0,0 +1,128 @@
+// Package save persists the game progress, settings and stats in a versioned schema.
+//
+// Saves are JSON documents with a "version" field. Older versions are brought
+// up to date by a chain of migrations when they are loaded, so adding fields
+// or changing the layout does not break the saves of earlier releases.
+package save
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"sisyphos.optimisticotter.me/sisyphos/daily"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
+)
+
+// Version is the current schema version.
+const Version = 1
+
+const (
+	// key is the storage key of the save.
+	key = "save"
+	// legacyDailyKey held the daily stats before there were saves.
+	// It is read as a version 0 save.
+	legacyDailyKey = "daily"
+)
+
+// ErrNewer is returned for a save written by a newer version of the game.
+var ErrNewer = errors.New("save: written by a newer version")
+
+// Save holds everything that outlives a session.
+type Save struct {
+	Version int `json:"version"`
+	// Endless is nil until the endless mode was played.
+	Endless  *Endless    `json:"endless,omitempty"`
+	Campaign Campaign    `json:"campaign"`
+	Settings Settings    `json:"settings"`
+	Daily    daily.Stats `json:"daily"`
+}
+
+// Endless holds the endless mode progress.
+type Endless struct {
+	Seed      uint64  `json:"seed"`
+	Level     int     `json:"level"`
+	Attempt   int     `json:"attempt"`
+	Score     int     `json:"score"`
+	BoardSize int     `json:"boardSize"`
+	Scale     float64 `json:"scale"`
+}
+
+// Campaign holds the position in the level packs.
+type Campaign struct {
+	Pack  int `json:"pack"`
+	Level int `json:"level"`
+}
+
+// Settings holds the player's choices.
+type Settings struct {
+	Edge rules.EdgeMode `json:"edge"`
+}
+
+// migrations[v] turns a version v save into a version v+1 one.
+var migrations = []func(data []byte) ([]byte, error){
+	// Version 0 is the bare daily stats.
+	func(data []byte) ([]byte, error) {
+		return json.Marshal(map[string]any{
+			"version": 1,
+			"daily":   json.RawMessage(data),
+		})
+	},
+}
+
+// Decode reads a save of any version up to Version.
+func Decode(data []byte) (Save, error) {
+	var head struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return Save{}, fmt.Errorf("save: %w", err)
+	}
+	if Version < head.Version {
+		return Save{}, ErrNewer
+	}
+	if head.Version < 0 {
+		return Save{}, fmt.Errorf("save: invalid version %d", head.Version)
+	}
+	for v := head.Version; v < Version; v++ {
+		var err error
+		if data, err = migrations[v](data); err != nil {
+			return Save{}, fmt.Errorf("save: migrating version %d: %w", v, err)
+		}
+	}
+	var s Save
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Save{}, fmt.Errorf("save: %w", err)
+	}
+	s.Version = Version
+	return s, nil
+}
+
+// Encode returns s in the current schema.
+func Encode(s Save) ([]byte, error) {
+	s.Version = Version
+	return json.Marshal(s)
+}
+
+// Load reads the save from st.
+// Load returns storage.ErrNotFound if nothing was saved yet.
+func Load(st storage.Store) (Save, error) {
+	data, err := st.Load(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		data, err = st.Load(legacyDailyKey)
+	}
+	if err != nil {
+		return Save{}, err
+	}
+	return Decode(data)
+}
+
+// Write saves s to st.
+func Write(st storage.Store, s Save) error {
+	data, err := Encode(s)
+	if err != nil {
+		return err
+	}
+	return st.Save(key, data)
+}
@@ -0,0 +1,58 @@
+package save_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/daily"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/save"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
+)
+
+func TestRoundTrip(t *testing.T) {
+	st := storage.NewMemory()
+	_, err := save.Load(st)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	s := save.Save{
+		Endless:  &save.Endless{Seed: 42, Level: 3, Attempt: 1, Score: 25, BoardSize: 4, Scale: 0.9},
+		Campaign: save.Campaign{Pack: 1, Level: 2},
+		Settings: save.Settings{Edge: rules.EdgeToroidal},
+	}
+	s.Daily.Record(time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC), 17)
+	require.NoError(t, save.Write(st, s))
+
+	loaded, err := save.Load(st)
+	require.NoError(t, err)
+	s.Version = save.Version
+	require.Equal(t, s, loaded)
+}
+
+func TestMigrateLegacyDaily(t *testing.T) {
+	st := storage.NewMemory()
+	require.NoError(t, st.Save("daily", []byte(`{"days":{"2024-12-31":{"completed":true,"moves":17}}}`)))
+
+	s, err := save.Load(st)
+	require.NoError(t, err)
+	require.Equal(t, save.Version, s.Version)
+	require.Nil(t, s.Endless)
+	require.Equal(t, daily.Day{Completed: true, Moves: 17}, s.Daily.Days["2024-12-31"])
+}
+
+func TestDecodeNewer(t *testing.T) {
+	_, err := save.Decode([]byte(`{"version":1000}`))
+	require.ErrorIs(t, err, save.ErrNewer)
+}
+
+func TestDecodeNegativeVersion(t *testing.T) {
+	_, err := save.Decode([]byte(`{"version":-1}`))
+	require.Error(t, err)
+}
+
+func TestDecodeUnknownFields(t *testing.T) {
+	s, err := save.Decode([]byte(`{"version":1,"campaign":{"pack":1,"level":0,"stars":3}}`))
+	require.NoError(t, err)
+	require.Equal(t, save.Campaign{Pack: 1}, s.Campaign)
+}
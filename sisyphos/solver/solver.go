@@ -0,0 +1,191 @@
+// Package solver searches for optimal solutions of sisyphos puzzles.
+//
+// The solver explores states through rules.State.Apply, so it follows
+// exactly the same rules as the game.
+package solver
+
+import (
+	"container/heap"
+	"errors"
+	"slices"
+
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+// DefaultMaxStates is the search limit used when Options.MaxStates is zero.
+const DefaultMaxStates = 200_000
+
+var (
+	// ErrUnsolvable is returned when the whole state space was searched without finding a solution.
+	ErrUnsolvable = errors.New("solver: puzzle is unsolvable")
+	// ErrLimit is returned when the search gave up before deciding the puzzle.
+	ErrLimit = errors.New("solver: state limit reached")
+)
+
+// Metric selects what a solution minimizes.
+type Metric int
+
+const (
+	// Moves minimizes the number of moves.
+	Moves Metric = iota
+	// Pushes minimizes the number of pushes, and then the number of moves.
+	Pushes
+)
+
+// Options configures a search.
+type Options struct {
+	Metric Metric
+	// MaxStates limits the number of discovered states.
+	MaxStates int
+}
+
+// Stats describes the work done by a search.
+type Stats struct {
+	// Explored is the number of expanded states.
+	Explored int
+	// Generated is the number of distinct states discovered.
+	Generated int
+	// DeadEnds is the number of expanded states that led to no new state.
+	DeadEnds int
+}
+
+// Solution represents the result of a search.
+type Solution struct {
+	Moves  []rules.Dir
+	Pushes int
+	Stats  Stats
+}
+
+type node struct {
+	state  rules.State
+	parent int
+	dir    rules.Dir
+	pushes int
+	moves  int
+}
+
+// cost orders nodes according to the metric.
+func (n *node) cost(metric Metric) int64 {
+	switch metric {
+	case Moves:
+		return int64(n.moves)
+	case Pushes:
+		return int64(n.pushes)<<32 | int64(n.moves)
+	}
+	panic("not reach")
+}
+
+// Solve searches for an optimal solution of s.
+//
+// Solve returns ErrUnsolvable if there is no solution, and ErrLimit if the
+// search was cut short. The returned Solution carries the search stats in any case.
+func Solve(s rules.State, opts Options) (Solution, error) {
+	maxStates := opts.MaxStates
+	if maxStates == 0 {
+		maxStates = DefaultMaxStates
+	}
+	nodes := []node{{state: s, parent: -1}}
+	best := map[string]int64{s.Hash(): 0}
+	q := &queue{nodes: &nodes, metric: opts.Metric}
+	heap.Push(q, 0)
+
+	var stats Stats
+	stats.Generated = 1
+	for q.Len() > 0 {
+		i := heap.Pop(q).(int)
+		n := nodes[i]
+		if best[n.state.Hash()] < n.cost(opts.Metric) {
+			// A cheaper path to this state was found after n was queued.
+			continue
+		}
+		stats.Explored++
+		if n.state.IsWon() {
+			return Solution{
+				Moves:  path(nodes, i),
+				Pushes: n.pushes,
+				Stats:  stats,
+			}, nil
+		}
+		children := 0
+		for dir := rules.DirUp; dir <= rules.DirLeft; dir++ {
+			next, result := n.state.Apply(dir)
+			if !result.Moved() {
+				continue
+			}
+			child := node{
+				state:  next,
+				parent: i,
+				dir:    dir,
+				pushes: n.pushes,
+				moves:  n.moves + 1,
+			}
+			if result.Pushed {
+				child.pushes++
+			}
+			key := next.Hash()
+			c, ok := best[key]
+			if ok && c <= child.cost(opts.Metric) {
+				continue
+			}
+			if !ok {
+				stats.Generated++
+				if stats.Generated > maxStates {
+					return Solution{Stats: stats}, ErrLimit
+				}
+			}
+			best[key] = child.cost(opts.Metric)
+			nodes = append(nodes, child)
+			heap.Push(q, len(nodes)-1)
+			children++
+		}
+		if children == 0 {
+			stats.DeadEnds++
+		}
+	}
+	return Solution{Stats: stats}, ErrUnsolvable
+}
+
+func path(nodes []node, i int) []rules.Dir {
+	var dirs []rules.Dir
+	for ; nodes[i].parent >= 0; i = nodes[i].parent {
+		dirs = append(dirs, nodes[i].dir)
+	}
+	slices.Reverse(dirs)
+	return dirs
+}
+
+// queue is a priority queue of node indices ordered by cost.
+type queue struct {
+	nodes   *[]node
+	metric  Metric
+	indices []int
+}
+
+func (q *queue) Len() int {
+	return len(q.indices)
+}
+
+func (q *queue) Less(i, j int) bool {
+	a := &(*q.nodes)[q.indices[i]]
+	b := &(*q.nodes)[q.indices[j]]
+	if a.cost(q.metric) != b.cost(q.metric) {
+		return a.cost(q.metric) < b.cost(q.metric)
+	}
+	// Prefer older nodes to keep the search breadth-first and deterministic.
+	return q.indices[i] < q.indices[j]
+}
+
+func (q *queue) Swap(i, j int) {
+	q.indices[i], q.indices[j] = q.indices[j], q.indices[i]
+}
+
+func (q *queue) Push(x any) {
+	q.indices = append(q.indices, x.(int))
+}
+
+func (q *queue) Pop() any {
+	n := len(q.indices)
+	x := q.indices[n-1]
+	q.indices = q.indices[:n-1]
+	return x
+}
@@ -0,0 +1,229 @@
+package solver_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/solver"
+	"sisyphos.optimisticotter.me/sisyphos/xsb"
+)
+
+// parse builds a State from XSB board rows.
+func parse(t *testing.T, rows ...string) rules.State {
+	levels, err := xsb.ParseString(strings.Join(rows, "\n"))
+	require.NoError(t, err)
+	require.Len(t, levels, 1)
+	return levels[0].State
+}
+
+func replay(t *testing.T, s rules.State, moves []rules.Dir) rules.State {
+	for _, d := range moves {
+		var result rules.MoveResult
+		s, result = s.Apply(d)
+		require.True(t, result.Moved())
+	}
+	return s
+}
+
+func TestSolve(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Rows   []string
+		Moves  int
+		Pushes int
+	}{
+		{
+			Name:  "solved",
+			Rows:  []string{"@*"},
+			Moves: 0,
+		},
+		{
+			Name:   "straight push",
+			Rows:   []string{"@$-."},
+			Moves:  2,
+			Pushes: 2,
+		},
+		{
+			Name: "walk around",
+			Rows: []string{
+				"---",
+				"-$@",
+				"-.-",
+			},
+			Moves:  3,
+			Pushes: 1,
+		},
+		{
+			Name: "mountains",
+			Rows: []string{
+				"@--#",
+				"#$--",
+				"--#-",
+				"-.--",
+			},
+			Moves:  3,
+			Pushes: 2,
+		},
+		{
+			Name: "two boulders",
+			Rows: []string{
+				"@$-.",
+				"----",
+				"-$-.",
+			},
+			Moves:  8,
+			Pushes: 4,
+		},
+		{
+			Name:   "slide on ice",
+			Rows:   []string{"@$~~."},
+			Moves:  1,
+			Pushes: 1,
+		},
+		{
+			Name:   "fill a pit",
+			Rows:   []string{"@$o$."},
+			Moves:  3,
+			Pushes: 2,
+		},
+		{
+			Name:   "cross one-way",
+			Rows:   []string{"@>$."},
+			Moves:  2,
+			Pushes: 1,
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			s := parse(t, test.Rows...)
+			sol, err := solver.Solve(s, solver.Options{})
+			require.NoError(t, err)
+			require.Len(t, sol.Moves, test.Moves)
+			require.Equal(t, test.Pushes, sol.Pushes)
+			require.True(t, replay(t, s, sol.Moves).IsWon())
+		})
+	}
+}
+
+func TestSolvePortal(t *testing.T) {
+	s := parse(t, "Portals: 2,0 4,0", "@$-#-.")
+	sol, err := solver.Solve(s, solver.Options{})
+	require.NoError(t, err)
+	require.Len(t, sol.Moves, 1)
+	require.True(t, replay(t, s, sol.Moves).IsWon())
+}
+
+func TestSolveGate(t *testing.T) {
+	// The spare boulder has to hold the plate down while the player goes through the gate.
+	s := parse(t,
+		"-----",
+		"@$=--",
+		"###|#",
+		".$---",
+	)
+	sol, err := solver.Solve(s, solver.Options{})
+	require.ErrorIs(t, err, solver.ErrUnsolvable)
+
+	s.Links = [][2]rules.Pos{{{X: 2, Y: 1}, {X: 3, Y: 2}}}
+	sol, err = solver.Solve(s, solver.Options{})
+	require.NoError(t, err)
+	require.Len(t, sol.Moves, 9)
+	require.True(t, replay(t, s, sol.Moves).IsWon())
+}
+
+func TestSolveKey(t *testing.T) {
+	// The key has to be fetched before the door lets the player behind the boulder.
+	s := parse(t,
+		"@--k",
+		"##d#",
+		".$--",
+	)
+	sol, err := solver.Solve(s, solver.Options{})
+	require.NoError(t, err)
+	require.Len(t, sol.Moves, 7)
+	require.True(t, replay(t, s, sol.Moves).IsWon())
+
+	// Without the key, the door stays locked.
+	s.SetCell(rules.Pos{X: 3, Y: 0}, rules.Floor)
+	_, err = solver.Solve(s, solver.Options{})
+	require.ErrorIs(t, err, solver.ErrUnsolvable)
+}
+
+func TestSolveSlope(t *testing.T) {
+	// The boulder has to be pushed up the slope in one go, over the crest onto the target.
+	s := parse(t,
+		"-----",
+		"@$LL.",
+	)
+	sol, err := solver.Solve(s, solver.Options{})
+	require.NoError(t, err)
+	require.Equal(t, []rules.Dir{rules.DirRight, rules.DirRight, rules.DirRight}, sol.Moves)
+	require.True(t, replay(t, s, sol.Moves).IsWon())
+
+	// The player cannot get round the boulder to push it off the slope sideways,
+	// as it rolls back down as soon as the player makes way.
+	rows := []string{
+		".D-",
+		"#D-",
+		"#$-",
+		"#@-",
+	}
+	_, err = solver.Solve(parse(t, rows...), solver.Options{})
+	require.ErrorIs(t, err, solver.ErrUnsolvable)
+	for i := range rows {
+		rows[i] = strings.ReplaceAll(rows[i], "D", "-")
+	}
+	_, err = solver.Solve(parse(t, rows...), solver.Options{})
+	require.NoError(t, err)
+}
+
+func TestSolveOneWayUnsolvable(t *testing.T) {
+	// The cell behind the boulder can only be entered from the boulder's side.
+	_, err := solver.Solve(parse(t, "@<$.", "----"), solver.Options{})
+	require.ErrorIs(t, err, solver.ErrUnsolvable)
+}
+
+func TestSolveUnsolvable(t *testing.T) {
+	s := parse(t,
+		"$--",
+		"-@-",
+		"--.",
+	)
+	sol, err := solver.Solve(s, solver.Options{})
+	require.ErrorIs(t, err, solver.ErrUnsolvable)
+	require.Nil(t, sol.Moves)
+	require.Positive(t, sol.Stats.Explored)
+	require.Positive(t, sol.Stats.DeadEnds)
+}
+
+func TestSolveLimit(t *testing.T) {
+	s := parse(t,
+		"------",
+		"-@--$-",
+		"------",
+		"--.---",
+	)
+	_, err := solver.Solve(s, solver.Options{MaxStates: 5})
+	require.ErrorIs(t, err, solver.ErrLimit)
+}
+
+func TestSolvePushes(t *testing.T) {
+	// The shortest solution pushes the boulder around, while walking
+	// around the boulder first takes more moves but fewer pushes.
+	s := parse(t,
+		"---#",
+		"--$@",
+		"---.",
+	)
+	byMoves, err := solver.Solve(s, solver.Options{Metric: solver.Moves})
+	require.NoError(t, err)
+	require.Len(t, byMoves.Moves, 8)
+	require.Equal(t, 4, byMoves.Pushes)
+	byPushes, err := solver.Solve(s, solver.Options{Metric: solver.Pushes})
+	require.NoError(t, err)
+	require.Len(t, byPushes.Moves, 10)
+	require.Equal(t, 2, byPushes.Pushes)
+	require.True(t, replay(t, s, byPushes.Moves).IsWon())
+}
@@ -0,0 +1,12 @@
+//go:build !js
+
+package storage
+
+// Default returns the Store for the current platform.
+func Default() (Store, error) {
+	f, err := NewFile()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
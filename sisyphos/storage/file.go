@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// File is a Store keeping each key in a file of its own within Dir.
+type File struct {
+	Dir string
+}
+
+// NewFile creates a File store in the sisyphos directory of the user config dir.
+func NewFile() (*File, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &File{Dir: filepath.Join(dir, "sisyphos")}, nil
+}
+
+func (s *File) path(key string) string {
+	return filepath.Join(s.Dir, key)
+}
+
+// Load implements Store.
+func (s *File) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Save implements Store.
+// The data is written to a temporary file first, so a crash never leaves a partial file behind.
+func (s *File) Save(key string, data []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
@@ -0,0 +1,57 @@
+//go:build js
+
+package storage
+
+import (
+	"encoding/base64"
+	"syscall/js"
+)
+
+// localStoragePrefix namespaces the keys within the page's localStorage.
+const localStoragePrefix = "sisyphos/"
+
+// Local is a Store backed by the browser's localStorage.
+// The data is base64 encoded, as localStorage only holds strings.
+type Local struct {
+	storage js.Value
+}
+
+// NewLocal creates a Local store.
+func NewLocal() *Local {
+	return &Local{
+		storage: js.Global().Get("localStorage"),
+	}
+}
+
+// Load implements Store.
+func (s *Local) Load(key string) ([]byte, error) {
+	v := s.storage.Call("getItem", localStoragePrefix+key)
+	if v.IsNull() {
+		return nil, ErrNotFound
+	}
+	return base64.StdEncoding.DecodeString(v.String())
+}
+
+// Save implements Store.
+func (s *Local) Save(key string, data []byte) (err error) {
+	// setItem throws when the quota is exceeded.
+	defer func() {
+		if r := recover(); r != nil {
+			if jsErr, ok := r.(js.Error); ok {
+				err = jsErr
+				return
+			}
+			panic(r)
+		}
+	}()
+	s.storage.Call("setItem", localStoragePrefix+key, base64.StdEncoding.EncodeToString(data))
+	return nil
+}
+
+// Default returns the Store for the current platform.
+func Default() (Store, error) {
+	if v := js.Global().Get("localStorage"); v.IsUndefined() || v.IsNull() {
+		return NewMemory(), nil
+	}
+	return NewLocal(), nil
+}
@@ -0,0 +1,49 @@
+// Package storage persists small blobs of game data across sessions.
+package storage
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned when there is no data saved under a key.
+var ErrNotFound = errors.New("storage: not found")
+
+// Store saves and loads data under string keys.
+// Keys consist of lowercase letters, digits, '-' and '_'.
+type Store interface {
+	Load(key string) ([]byte, error)
+	Save(key string, data []byte) error
+}
+
+// Memory is a Store keeping the data in memory only.
+type Memory struct {
+	m    sync.Mutex
+	data map[string][]byte
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		data: map[string][]byte{},
+	}
+}
+
+// Load implements Store.
+func (s *Memory) Load(key string) ([]byte, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	data, ok := s.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Save implements Store.
+func (s *Memory) Save(key string, data []byte) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.data[key] = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,32 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/storage"
+)
+
+func testStore(t *testing.T, s storage.Store) {
+	_, err := s.Load("stats")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.NoError(t, s.Save("stats", []byte("1")))
+	require.NoError(t, s.Save("other", []byte("2")))
+	require.NoError(t, s.Save("stats", []byte("3")))
+
+	data, err := s.Load("stats")
+	require.NoError(t, err)
+	require.Equal(t, []byte("3"), data)
+	data, err = s.Load("other")
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), data)
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, storage.NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	testStore(t, &storage.File{Dir: t.TempDir() + "/sisyphos"})
+}
@@ -0,0 +1,40 @@
+package sisyphos
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+const stuckFontSize = 48
+
+// drawStuck marks the boulder that cannot reach a target any more, or the
+// target left without a boulder, and covers the board with an overlay offering a way out.
+func (b *Board) drawStuck(boardImage *ebiten.Image) {
+	if b.stuck == nil || len(b.tasks) != 0 {
+		return
+	}
+	op := &ebiten.DrawImageOptions{}
+	x := b.stuck.X*tileSize + (b.stuck.X+1)*tileMargin
+	y := b.stuck.Y*tileSize + (b.stuck.Y+1)*tileMargin
+	op.GeoM.Translate(float64(x), float64(y))
+	op.ColorScale.ScaleWithColor(stuckColor)
+	boardImage.DrawImage(tileImage, op)
+
+	w, h := boardImage.Bounds().Dx(), boardImage.Bounds().Dy()
+	op = &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(w)/tileSize, float64(h)/tileSize)
+	op.ColorScale.ScaleWithColor(overlayColor)
+	boardImage.DrawImage(tileImage, op)
+
+	top := &text.DrawOptions{}
+	top.GeoM.Translate(float64(w)/2, float64(h)/2)
+	top.PrimaryAlign = text.AlignCenter
+	top.SecondaryAlign = text.AlignCenter
+	top.LineSpacing = stuckFontSize * hudLineSpacing
+	top.ColorScale.ScaleWithColor(hudColor)
+	face := &text.GoTextFace{
+		Source: mplusFaceSource,
+		Size:   stuckFontSize,
+	}
+	text.Draw(boardImage, "stuck\nZ: undo\nR: restart", face, top)
+}
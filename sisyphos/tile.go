@@ -1,11 +1,12 @@
 package sisyphos
 
 import (
-	"errors"
+	"image/color"
 	"log"
-	"math/rand/v2"
+	"slices"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
 )
 
 // TileData represents a tile information like a value and a position.
@@ -23,33 +24,40 @@ type Tile struct {
 	// next is empty when the tile is not about to move.
 	next TileData
 
+	// dir is the direction of the current move and dist the number of cells travelled.
+	// wrap is true if the move crosses the border of a toroidal board.
+	dir  Dir
+	dist int
+	wrap bool
+
 	movingCount       int
 	startPoppingCount int
 	poppingCount      int
-}
+	// fillingCount runs while a tile is about to vanish, e.g. while a boulder falls
+	// into a pit or the player picks up a key: the tiles shrink away once the
+	// moving piece has arrived, and are removed.
+	fillingCount int
 
-// Pos returns the tile's current position.
-// Pos is used only at testing so far.
-func (t *Tile) Pos() (int, int) {
-	return t.current.x, t.current.y
-}
+	// exit is the second leg of a move through a portal. The tile shrinks into
+	// the portal, then grows out of its partner while taking the second leg.
+	exit    *portalExit
+	growing bool
 
-// NextPos returns the tile's next position.
-// NextPos is used only at testing so far.
-func (t *Tile) NextPos() (int, int) {
-	return t.next.x, t.next.y
-}
+	// tint colors the tile, e.g. to tell portal pairs apart. tint is nil for most tiles.
+	tint color.Color
 
-// Value returns the tile's current value.
-// Value is used only at testing so far.
-func (t *Tile) Value() SpriteType {
-	return t.current.value
+	// open is true if the tile is an open gate. togglingCount runs while the gate
+	// opens or closes, see toggleGates.
+	open          bool
+	togglingCount int
 }
 
-// NextValue returns the tile's current value.
-// NextValue is used only at testing so far.
-func (t *Tile) NextValue() SpriteType {
-	return t.next.value
+// portalExit represents the part of a move after a piece comes out of a portal.
+type portalExit struct {
+	from TileData
+	to   TileData
+	dist int
+	wrap bool
 }
 
 // NewTile creates a new Tile object.
@@ -66,25 +74,54 @@ func NewTile(value SpriteType, x, y int) *Tile {
 
 // IsMoving returns a boolean value indicating if the tile is animating.
 func (t *Tile) IsMoving() bool {
-	return 0 < t.movingCount
+	return 0 < t.movingCount || 0 < t.fillingCount
 }
 
 func (t *Tile) stopAnimation() {
 	if 0 < t.movingCount {
+		if t.exit != nil {
+			t.next = t.exit.to
+			t.exit = nil
+		}
 		t.current = t.next
 		t.next = TileData{}
 	}
+	if 0 < t.fillingCount {
+		t.current.value = EmptySprite
+	}
 	t.movingCount = 0
 	t.startPoppingCount = 0
 	t.poppingCount = 0
+	t.fillingCount = 0
+	t.togglingCount = 0
+	t.growing = false
 }
 
-func tileAt(tiles map[*Tile]struct{}, x, y int) *Tile {
+// isGround returns true if value is terrain that pieces move over, rather than a piece or a target.
+func isGround(value SpriteType) bool {
+	switch value {
+	case IceSprite, PitSprite, PortalSprite, OneWayUpSprite, OneWayRightSprite, OneWayDownSprite, OneWayLeftSprite,
+		PlateSprite, GateSprite, KeySprite, DoorSprite, SlopeUpSprite, SlopeRightSprite, SlopeDownSprite, SlopeLeftSprite:
+		return true
+	}
+	return false
+}
+
+// pieceAt returns the player or boulder tile at (x, y), or nil if there is none.
+func pieceAt(tiles map[*Tile]struct{}, x, y int) *Tile {
+	return tileAt(tiles, x, y, PlayerSprite, BoulderSprite)
+}
+
+// tileAt returns the tile at (x, y) showing one of values, or nil if there is none.
+func tileAt(tiles map[*Tile]struct{}, x, y int, values ...SpriteType) *Tile {
 	var result *Tile
 	for t := range tiles {
 		if t.current.x != x || t.current.y != y {
 			continue
 		}
+		if !slices.Contains(values, t.current.value) {
+			continue
+		}
 		if result != nil {
 			panic("not reach")
 		}
@@ -93,90 +130,172 @@ func tileAt(tiles map[*Tile]struct{}, x, y int) *Tile {
 	return result
 }
 
-const ()
-
-// MoveTiles moves tiles in the given tiles map if possible.
-// MoveTiles returns true if there are tiles that are to move, otherwise false.
-//
-// When MoveTiles is called, all tiles must not be about to move.
-func MoveTiles(tiles map[*Tile]struct{}, size int, dir Dir) bool {
-	for t := range tiles {
-		if t.current.value == PlayerSprite {
-			nx, ny := t.current.x, t.current.y
-			nnx, nny := nx, ny
-			switch dir {
-			case DirUp:
-				ny -= 1
-				nny -= 2
-			case DirDown:
-				ny += 1
-				nny += 2
-			case DirLeft:
-				nx -= 1
-				nnx -= 2
-			case DirRight:
-				nx += 1
-				nnx += 2
+// tilesFromState creates tiles representing s.
+func tilesFromState(s rules.State) map[*Tile]struct{} {
+	tiles := map[*Tile]struct{}{}
+	for y := 0; y < s.Height; y++ {
+		for x := 0; x < s.Width; x++ {
+			c := s.CellAt(rules.Pos{X: x, Y: y})
+			if dir, ok := c.OneWay(); ok {
+				tiles[NewTile(OneWayUpSprite+SpriteType(dir), x, y)] = struct{}{}
 			}
-			next := tileAt(tiles, nx, ny)
-			if next == nil || next.Value() == TargetSprite {
-				nextData := TileData{t.current.value, nx, ny}
-				t.next = nextData
-				t.movingCount = maxMovingCount
-				return true
+			if dir, ok := c.Slope(); ok {
+				tiles[NewTile(SlopeUpSprite+SpriteType(dir), x, y)] = struct{}{}
 			}
-			if next.Value() == BoulderSprite {
-				nNext := tileAt(tiles, nnx, nny)
-				if nNext == nil || nNext.Value() == TargetSprite {
-					nextData := TileData{t.current.value, nx, ny}
-					t.next = nextData
-					t.movingCount = maxMovingCount
-
-					nextNextData := TileData{next.Value(), nnx, nny}
-					next.next = nextNextData
-					next.movingCount = maxMovingCount
-					return true
-				}
-
+			switch c {
+			case rules.Wall:
+				tiles[NewTile(MountainSprite, x, y)] = struct{}{}
+			case rules.Ice:
+				tiles[NewTile(IceSprite, x, y)] = struct{}{}
+			case rules.Pit:
+				tiles[NewTile(PitSprite, x, y)] = struct{}{}
+			case rules.Plate:
+				tiles[NewTile(PlateSprite, x, y)] = struct{}{}
+			case rules.Gate:
+				t := NewTile(GateSprite, x, y)
+				t.open = s.GateOpen(rules.Pos{X: x, Y: y})
+				tiles[t] = struct{}{}
+			case rules.Key:
+				tiles[NewTile(KeySprite, x, y)] = struct{}{}
+			case rules.Door:
+				tiles[NewTile(DoorSprite, x, y)] = struct{}{}
 			}
 		}
 	}
-	return false
+	// A plate or gate with several links takes the color of the first one.
+	for i, l := range s.Links {
+		for _, p := range l {
+			if t := tileAt(tiles, p.X, p.Y, PlateSprite, GateSprite); t != nil && t.tint == nil {
+				t.tint = linkColors[i%len(linkColors)]
+			}
+		}
+	}
+	for i, pair := range s.Portals {
+		for _, p := range pair {
+			t := NewTile(PortalSprite, p.X, p.Y)
+			t.tint = portalColors[i%len(portalColors)]
+			tiles[t] = struct{}{}
+		}
+	}
+	for _, p := range s.Targets {
+		tiles[NewTile(TargetSprite, p.X, p.Y)] = struct{}{}
+	}
+	for _, p := range s.Boulders {
+		tiles[NewTile(BoulderSprite, p.X, p.Y)] = struct{}{}
+	}
+	tiles[NewTile(PlayerSprite, s.Player.X, s.Player.Y)] = struct{}{}
+	return tiles
 }
 
-func addRandomTile(tiles map[*Tile]struct{}, size int, sprite SpriteType) error {
-	cells := make([]bool, size*size)
-	for t := range tiles {
-		if t.IsMoving() {
+// animateTiles starts the moving animation of tiles according to moves, which are made at once.
+func animateTiles(tiles map[*Tile]struct{}, moves []rules.Move) {
+	// Look up all the tiles first, as a tile may move to where another one was.
+	moving := make([]*Tile, len(moves))
+	for i, m := range moves {
+		if m.Unfill {
+			// The pit opens again and the boulder climbs out of it.
+			tiles[NewTile(PitSprite, m.From.X, m.From.Y)] = struct{}{}
+			t := NewTile(BoulderSprite, m.From.X, m.From.Y)
+			t.startPoppingCount = 0
+			tiles[t] = struct{}{}
+			moving[i] = t
+			continue
+		}
+		t := pieceAt(tiles, m.From.X, m.From.Y)
+		if t == nil {
 			panic("not reach")
 		}
-		i := t.current.x + t.current.y*size
-		cells[i] = true
+		moving[i] = t
 	}
-	availableCells := []int{}
-	for i, b := range cells {
-		if b {
+	for i, m := range moves {
+		t := moving[i]
+		t.dir = m.Dir
+		to, dist := m.To, m.Dist
+		if m.Portal {
+			to, dist = m.Via[0], m.ViaDist
+			t.exit = &portalExit{
+				from: TileData{t.current.value, m.Via[1].X, m.Via[1].Y},
+				to:   TileData{t.current.value, m.To.X, m.To.Y},
+				dist: m.Dist - m.ViaDist,
+				wrap: wraps(m.Via[1], m.To, m.Dir, m.Dist-m.ViaDist),
+			}
+		}
+		t.next = TileData{t.current.value, to.X, to.Y}
+		t.dist = dist
+		t.wrap = wraps(m.From, to, m.Dir, dist)
+		// Sliding pieces keep the walking speed.
+		t.movingCount = maxMovingCount * dist
+		if m.Fill {
+			t.fillingCount = maxMovingCount*m.Dist + maxFillingCount
+			pit := tileAt(tiles, m.To.X, m.To.Y, PitSprite)
+			if pit == nil {
+				panic("not reach")
+			}
+			pit.fillingCount = t.fillingCount
+		}
+		if m.Pickup || m.Unlock {
+			// The key, or the door, vanishes as the player arrives.
+			cell := tileAt(tiles, m.To.X, m.To.Y, KeySprite, DoorSprite)
+			if cell == nil {
+				panic("not reach")
+			}
+			cell.fillingCount = maxMovingCount*m.Dist + maxFillingCount
+		}
+		// The key, or the door, comes back as the player leaves.
+		if m.Drop {
+			tiles[NewTile(KeySprite, m.From.X, m.From.Y)] = struct{}{}
+		}
+		if m.Lock {
+			tiles[NewTile(DoorSprite, m.From.X, m.From.Y)] = struct{}{}
+		}
+	}
+}
+
+// toggleGates starts opening or closing the gate tiles whose state differs from s.
+func toggleGates(tiles map[*Tile]struct{}, s rules.State) {
+	for t := range tiles {
+		if t.current.value != GateSprite {
 			continue
 		}
-		availableCells = append(availableCells, i)
+		open := s.GateOpen(rules.Pos{X: t.current.x, Y: t.current.y})
+		if t.open != open {
+			t.open = open
+			t.togglingCount = maxTogglingCount
+		}
 	}
-	if len(availableCells) == 0 {
-		return errors.New("sisyphos: there is no space to add a new tile")
+}
+
+// wraps returns true if going dist cells from from in dir to reach to crosses the border of a toroidal board.
+func wraps(from, to rules.Pos, dir Dir, dist int) bool {
+	for i := 0; i < dist; i++ {
+		from = from.Add(dir)
 	}
-	c := availableCells[rand.IntN(len(availableCells))]
-	x := c % size
-	y := c / size
-	t := NewTile(sprite, x, y)
-	tiles[t] = struct{}{}
-	return nil
+	return from != to
 }
 
 // Update updates the tile's animation states.
 func (t *Tile) Update() error {
+	if 0 < t.fillingCount {
+		t.fillingCount--
+		if t.fillingCount == 0 {
+			t.current.value = EmptySprite
+		}
+	}
 	switch {
 	case 0 < t.movingCount:
 		t.movingCount--
+		if t.movingCount == 0 && t.exit != nil {
+			// Come out of the partner portal.
+			e := t.exit
+			t.exit = nil
+			t.current, t.next = e.from, e.to
+			t.dist, t.wrap = e.dist, e.wrap
+			t.movingCount = maxMovingCount * e.dist
+			t.growing = true
+			break
+		}
 		if t.movingCount == 0 {
+			t.growing = false
 			if t.current.value != t.next.value && 0 < t.next.value {
 				t.poppingCount = maxPoppingCount
 			}
@@ -188,13 +307,12 @@ func (t *Tile) Update() error {
 	case 0 < t.poppingCount:
 		t.poppingCount--
 	}
+	if 0 < t.togglingCount {
+		t.togglingCount--
+	}
 	return nil
 }
 
-func mean(a, b int, rate float64) int {
-	return int(float64(a)*(1-rate) + float64(b)*rate)
-}
-
 func meanF(a, b float64, rate float64) float64 {
 	return a*(1-rate) + b*rate
 }
@@ -202,27 +320,57 @@ func meanF(a, b float64, rate float64) float64 {
 // Draw draws the current tile to the given boardImage.
 func (t *Tile) Draw(boardImage *ebiten.Image) {
 	i, j := t.current.x, t.current.y
-	ni, nj := t.next.x, t.next.y
 	v := t.current.value
 	if v == EmptySprite {
 		return
 	}
 	op := &ebiten.DrawImageOptions{}
+	if t.tint != nil {
+		op.ColorScale.ScaleWithColor(t.tint)
+	}
+	if v == GateSprite {
+		// Open gates fade into the ground.
+		const openAlpha = 0.25
+		open := 0.0
+		if t.open {
+			open = 1
+		}
+		if 0 < t.togglingCount {
+			open = meanF(open, 1-open, float64(t.togglingCount)/maxTogglingCount)
+		}
+		op.ColorScale.ScaleAlpha(float32(meanF(1, openAlpha, open)))
+	}
 	x := i*tileSize + (i+1)*tileMargin
 	y := j*tileSize + (j+1)*tileMargin
-	nx := ni*tileSize + (ni+1)*tileMargin
-	ny := nj*tileSize + (nj+1)*tileMargin
 	switch {
 	case 0 < t.movingCount:
-		rate := 1 - float64(t.movingCount)/maxMovingCount
-		x = mean(x, nx, rate)
-		y = mean(y, ny, rate)
+		rate := 1 - float64(t.movingCount)/float64(maxMovingCount*t.dist)
+		dx, dy := t.dir.Vector()
+		d := int(rate * float64(t.dist*(tileSize+tileMargin)))
+		x += dx * d
+		y += dy * d
+		switch {
+		case t.exit != nil:
+			// Shrink into the portal.
+			scaleTile(op, 1-rate)
+		case t.growing:
+			scaleTile(op, rate)
+		}
+		if t.wrap {
+			// Slide out over one border and in over the opposite one.
+			// Both copies are clipped by boardImage.
+			outOp := *op
+			outOp.GeoM.Translate(float64(x), float64(y))
+			boardImage.DrawImage(tileSprite(v), &outOp)
+			x -= dx * (boardImage.Bounds().Dx() - tileMargin)
+			y -= dy * (boardImage.Bounds().Dy() - tileMargin)
+		}
+	case 0 < t.fillingCount:
+		// The boulder sinks into the pit as the pit closes.
+		scaleTile(op, min(1, float64(t.fillingCount)/maxFillingCount))
 	case 0 < t.startPoppingCount:
 		rate := 1 - float64(t.startPoppingCount)/float64(maxPoppingCount)
-		scale := meanF(0.0, 1.0, rate)
-		op.GeoM.Translate(float64(-tileSize/2), float64(-tileSize/2))
-		op.GeoM.Scale(scale, scale)
-		op.GeoM.Translate(float64(tileSize/2), float64(tileSize/2))
+		scaleTile(op, meanF(0.0, 1.0, rate))
 	case 0 < t.poppingCount:
 		const maxScale = 1.2
 		rate := 0.0
@@ -233,15 +381,19 @@ func (t *Tile) Draw(boardImage *ebiten.Image) {
 			// 1 to 0
 			rate = float64(t.poppingCount) / float64(maxPoppingCount*2/3)
 		}
-		scale := meanF(1.0, maxScale, rate)
-		op.GeoM.Translate(float64(-tileSize/2), float64(-tileSize/2))
-		op.GeoM.Scale(scale, scale)
-		op.GeoM.Translate(float64(tileSize/2), float64(tileSize/2))
+		scaleTile(op, meanF(1.0, maxScale, rate))
 	}
 	op.GeoM.Translate(float64(x), float64(y))
 	boardImage.DrawImage(tileSprite(v), op)
 }
 
+// scaleTile scales a tile drawn with op around its center.
+func scaleTile(op *ebiten.DrawImageOptions, scale float64) {
+	op.GeoM.Translate(float64(-tileSize/2), float64(-tileSize/2))
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(tileSize/2), float64(tileSize/2))
+}
+
 func tileSprite(value SpriteType) *ebiten.Image {
 	switch value {
 	case PlayerSprite:
@@ -252,6 +404,24 @@ func tileSprite(value SpriteType) *ebiten.Image {
 		return mountainImage
 	case TargetSprite:
 		return targetImage
+	case IceSprite:
+		return iceImage
+	case PitSprite:
+		return pitImage
+	case PortalSprite:
+		return portalImage
+	case OneWayUpSprite, OneWayRightSprite, OneWayDownSprite, OneWayLeftSprite:
+		return oneWayImages[value-OneWayUpSprite]
+	case SlopeUpSprite, SlopeRightSprite, SlopeDownSprite, SlopeLeftSprite:
+		return slopeImages[value-SlopeUpSprite]
+	case PlateSprite:
+		return plateImage
+	case GateSprite:
+		return gateImage
+	case KeySprite:
+		return keyImage
+	case DoorSprite:
+		return doorImage
 	}
 	log.Println(value)
 	panic("not reach")
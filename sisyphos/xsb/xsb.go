@@ -0,0 +1,484 @@
+// Package xsb reads and writes levels in the XSB Sokoban text format.
+//
+// A level file holds any number of levels separated by blank lines:
+//
+//	; comment
+//	Title: First steps
+//	#####
+//	#@$.#
+//	#####
+//
+// Board rows use '#' for walls (mountains), '@' for the player, '$' for boulders,
+// '.' for targets, '*' for a boulder on a target, '+' for the player on a target,
+// ' ', '-' or '_' for floor, '~' for ice, 'o' for pits, '^', '>', 'v', '<'
+// for one-way cells, '=' for plates, '|' for gates, 'k' for keys, 'd' for doors and
+// 'U', 'R', 'D', 'L' for slopes running downhill up, right, down and left.
+// Rows without walls, e.g. of toroidal boards, should use '-' for floor so that they
+// are not mistaken for text. Ice, pits, one-way cells, plates, gates, keys, doors and
+// slopes are not part of the XSB standard, and the pieces and targets on them cannot
+// be written: Format writes them on floor.
+//
+// Lines starting with ';' are comments. "Key: value" lines hold metadata, e.g.
+// "Title" or "Author", and "Edge: Toroidal" selects the toroidal edge mode.
+// "Portals" lists the portal pairs as the 0-based column and row of both ends,
+// with pairs separated by ';', e.g. "Portals: 3,1 5,1; 2,4 6,2", and "Links"
+// lists the linked plates and gates the same way, each plate before its gate.
+// Any other text line is taken as the title if the level has none yet, and as
+// a comment otherwise. Text lines before a board belong to it, as do the lines
+// right after it up to the next blank line.
+package xsb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+)
+
+const (
+	wall            = '#'
+	player          = '@'
+	playerOnTarget  = '+'
+	boulder         = '$'
+	boulderOnTarget = '*'
+	target          = '.'
+	floor           = ' '
+	ice             = '~'
+	pit             = 'o'
+	plate           = '='
+	gate            = '|'
+	key             = 'k'
+	door            = 'd'
+)
+
+// oneWays and slopes hold the one-way and slope cell characters in the order of rules.Dir.
+const (
+	oneWays = "^>v<"
+	slopes  = "URDL"
+)
+
+const (
+	titleKey   = "Title"
+	edgeKey    = "Edge"
+	portalsKey = "Portals"
+	linksKey   = "Links"
+)
+
+// Level represents a single level of a level file.
+type Level struct {
+	Title    string
+	Comments []string
+	// Meta holds the metadata other than the title, the edge mode, the portals and the links.
+	Meta  map[string]string
+	State rules.State
+}
+
+// SyntaxError represents malformed input.
+type SyntaxError struct {
+	// Line and Col are 1-based.
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("xsb: %d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// isBoardRow returns true if line is a row of a board rather than text.
+func isBoardRow(line string) bool {
+	t := strings.TrimLeft(line, " ")
+	if t == "" {
+		return false
+	}
+	if t[0] == wall {
+		return true
+	}
+	// Other rows must consist of board characters only, and hold a piece or a
+	// symbol other than a letter. Rows of targets, plates, gates, pits, keys, doors
+	// and slopes alone are not accepted, so that e.g. "...", "===" or "Look" stays text.
+	if !strings.ContainsAny(line, "#@+$*-_~^><") {
+		return false
+	}
+	for _, c := range line {
+		if _, ok := cellOf(c); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type cell struct {
+	wall, ice, pit, plate, gate, key, door, player, boulder, target bool
+	// oneWay and slope are Floor unless the cell is a one-way cell or a slope.
+	oneWay, slope rules.Cell
+}
+
+func cellOf(c rune) (cell, bool) {
+	switch c {
+	case wall:
+		return cell{wall: true}, true
+	case player:
+		return cell{player: true}, true
+	case playerOnTarget:
+		return cell{player: true, target: true}, true
+	case boulder:
+		return cell{boulder: true}, true
+	case boulderOnTarget:
+		return cell{boulder: true, target: true}, true
+	case target:
+		return cell{target: true}, true
+	case ice:
+		return cell{ice: true}, true
+	case pit:
+		return cell{pit: true}, true
+	case plate:
+		return cell{plate: true}, true
+	case gate:
+		return cell{gate: true}, true
+	case key:
+		return cell{key: true}, true
+	case door:
+		return cell{door: true}, true
+	case floor, '-', '_':
+		return cell{}, true
+	}
+	if i := strings.IndexRune(oneWays, c); 0 <= i {
+		return cell{oneWay: rules.OneWay(rules.Dir(i))}, true
+	}
+	if i := strings.IndexRune(slopes, c); 0 <= i {
+		return cell{slope: rules.Slope(rules.Dir(i))}, true
+	}
+	return cell{}, false
+}
+
+// Parse reads all the levels from r.
+// Parse returns a *SyntaxError if the input is malformed.
+func Parse(r io.Reader) ([]Level, error) {
+	var (
+		levels []Level
+		// pending holds the text read before the next board.
+		pending Level
+		rows    []string
+		first   int
+		// trailing is true while the text belongs to the last board.
+		trailing bool
+	)
+	finishBoard := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		s, err := parseBoard(rows, first)
+		if err != nil {
+			return err
+		}
+		s.Edge = pending.State.Edge
+		s.Portals = pending.State.Portals
+		s.Links = pending.State.Links
+		if msg, ok := checkState(s); !ok {
+			return &SyntaxError{Line: first, Col: 1, Msg: msg}
+		}
+		pending.State = s
+		levels = append(levels, pending)
+		pending = Level{}
+		rows = nil
+		trailing = true
+		return nil
+	}
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), " \r")
+		if isBoardRow(line) {
+			if len(rows) == 0 {
+				first = n
+				trailing = false
+			}
+			rows = append(rows, line)
+			continue
+		}
+		if err := finishBoard(); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			trailing = false
+			continue
+		}
+		l := &pending
+		if trailing {
+			l = &levels[len(levels)-1]
+		}
+		if err := l.addText(line, n); err != nil {
+			return nil, err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := finishBoard(); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// ParseString is like Parse, but reads the levels from a string.
+func ParseString(s string) ([]Level, error) {
+	return Parse(strings.NewReader(s))
+}
+
+func (l *Level) addText(line string, n int) error {
+	if c, ok := strings.CutPrefix(strings.TrimLeft(line, " "), ";"); ok {
+		l.Comments = append(l.Comments, strings.TrimSpace(c))
+		return nil
+	}
+	if key, value, ok := cutMeta(line); ok {
+		switch key {
+		case titleKey:
+			l.Title = value
+		case edgeKey:
+			edge, ok := parseEdge(value)
+			if !ok {
+				return &SyntaxError{Line: n, Col: strings.Index(line, value) + 1, Msg: fmt.Sprintf("unknown edge mode %q", value)}
+			}
+			l.State.Edge = edge
+		case portalsKey:
+			portals, ok := parsePairs(value)
+			if !ok {
+				return &SyntaxError{Line: n, Col: strings.Index(line, value) + 1, Msg: fmt.Sprintf("invalid portals %q", value)}
+			}
+			l.State.Portals = portals
+			// The board is known when the key follows it.
+			if msg, ok := checkState(l.State); l.State.Grid != nil && !ok {
+				return &SyntaxError{Line: n, Col: strings.Index(line, value) + 1, Msg: msg}
+			}
+		case linksKey:
+			links, ok := parsePairs(value)
+			if !ok {
+				return &SyntaxError{Line: n, Col: strings.Index(line, value) + 1, Msg: fmt.Sprintf("invalid links %q", value)}
+			}
+			l.State.Links = links
+			if msg, ok := checkState(l.State); l.State.Grid != nil && !ok {
+				return &SyntaxError{Line: n, Col: strings.Index(line, value) + 1, Msg: msg}
+			}
+		default:
+			if l.Meta == nil {
+				l.Meta = map[string]string{}
+			}
+			l.Meta[key] = value
+		}
+		return nil
+	}
+	if l.Title == "" {
+		l.Title = strings.TrimSpace(line)
+		return nil
+	}
+	l.Comments = append(l.Comments, strings.TrimSpace(line))
+	return nil
+}
+
+// cutMeta splits a "Key: value" line. Keys are single words.
+func cutMeta(line string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(line, ":")
+	if !ok || key == "" {
+		return "", "", false
+	}
+	for _, c := range key {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return "", "", false
+		}
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+func parseEdge(value string) (rules.EdgeMode, bool) {
+	for _, e := range []rules.EdgeMode{rules.EdgeWalled, rules.EdgeToroidal} {
+		if strings.EqualFold(value, e.String()) {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// parsePairs parses the pairs of distinct cells of the Portals and Links keys.
+func parsePairs(value string) ([][2]rules.Pos, bool) {
+	var pairs [][2]rules.Pos
+	for _, pair := range strings.Split(value, ";") {
+		var p [2]rules.Pos
+		_, err := fmt.Sscanf(strings.TrimSpace(pair), "%d,%d %d,%d", &p[0].X, &p[0].Y, &p[1].X, &p[1].Y)
+		if err != nil || p[0] == p[1] || p[0].X < 0 || p[0].Y < 0 || p[1].X < 0 || p[1].Y < 0 {
+			return nil, false
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, true
+}
+
+// checkState returns a message and false if s is not a valid rules.State,
+// e.g. if a portal lies on a wall or a link does not join a plate to a gate.
+func checkState(s rules.State) (string, bool) {
+	var stateErr *rules.StateError
+	if errors.As(s.Validate(), &stateErr) {
+		return stateErr.Msg, false
+	}
+	return "", true
+}
+
+func formatPairs(pairs [][2]rules.Pos) string {
+	ps := make([]string, len(pairs))
+	for i, p := range pairs {
+		ps[i] = fmt.Sprintf("%d,%d %d,%d", p[0].X, p[0].Y, p[1].X, p[1].Y)
+	}
+	return strings.Join(ps, "; ")
+}
+
+func parseBoard(rows []string, first int) (rules.State, error) {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	s := rules.New(width, len(rows))
+	hasPlayer := false
+	for y, row := range rows {
+		for x, c := range row {
+			cell, ok := cellOf(c)
+			if !ok {
+				return rules.State{}, &SyntaxError{Line: first + y, Col: x + 1, Msg: fmt.Sprintf("invalid character %q", c)}
+			}
+			p := rules.Pos{X: x, Y: y}
+			switch {
+			case cell.wall:
+				s.SetCell(p, rules.Wall)
+			case cell.ice:
+				s.SetCell(p, rules.Ice)
+			case cell.pit:
+				s.SetCell(p, rules.Pit)
+			case cell.plate:
+				s.SetCell(p, rules.Plate)
+			case cell.gate:
+				s.SetCell(p, rules.Gate)
+			case cell.key:
+				s.SetCell(p, rules.Key)
+			case cell.door:
+				s.SetCell(p, rules.Door)
+			case cell.oneWay != rules.Floor:
+				s.SetCell(p, cell.oneWay)
+			case cell.slope != rules.Floor:
+				s.SetCell(p, cell.slope)
+			}
+			if cell.player {
+				if hasPlayer {
+					return rules.State{}, &SyntaxError{Line: first + y, Col: x + 1, Msg: "more than one player"}
+				}
+				s.Player = p
+				hasPlayer = true
+			}
+			if cell.boulder {
+				s.Boulders = append(s.Boulders, p)
+			}
+			if cell.target {
+				s.Targets = append(s.Targets, p)
+			}
+		}
+	}
+	if !hasPlayer {
+		return rules.State{}, &SyntaxError{Line: first, Col: 1, Msg: "no player"}
+	}
+	if len(s.Boulders) < len(s.Targets) {
+		return rules.State{}, &SyntaxError{Line: first, Col: 1, Msg: fmt.Sprintf("%d boulders for %d targets", len(s.Boulders), len(s.Targets))}
+	}
+	return s, nil
+}
+
+// Format returns the board rows of s.
+func Format(s rules.State) string {
+	var b strings.Builder
+	for y := 0; y < s.Height; y++ {
+		row := make([]byte, s.Width)
+		for x := 0; x < s.Width; x++ {
+			row[x] = formatCell(s, rules.Pos{X: x, Y: y})
+		}
+		line := strings.TrimRight(string(row), " ")
+		if !isBoardRow(line) {
+			// Spell out the floor so that the row is not read as text.
+			line = strings.ReplaceAll(string(row), " ", "-")
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func formatCell(s rules.State, p rules.Pos) byte {
+	switch {
+	case s.CellAt(p) == rules.Wall:
+		return wall
+	case s.CellAt(p) == rules.Pit:
+		return pit
+	case s.Player == p && s.IsTarget(p):
+		return playerOnTarget
+	case s.Player == p:
+		return player
+	case s.BoulderAt(p) >= 0 && s.IsTarget(p):
+		return boulderOnTarget
+	case s.BoulderAt(p) >= 0:
+		return boulder
+	case s.IsTarget(p):
+		return target
+	case s.CellAt(p) == rules.Ice:
+		return ice
+	case s.CellAt(p) == rules.Plate:
+		return plate
+	case s.CellAt(p) == rules.Gate:
+		return gate
+	case s.CellAt(p) == rules.Key:
+		return key
+	case s.CellAt(p) == rules.Door:
+		return door
+	}
+	if dir, ok := s.CellAt(p).OneWay(); ok {
+		return oneWays[dir]
+	}
+	if dir, ok := s.CellAt(p).Slope(); ok {
+		return slopes[dir]
+	}
+	return floor
+}
+
+// Write writes levels to w, separated by blank lines.
+func Write(w io.Writer, levels []Level) error {
+	bw := bufio.NewWriter(w)
+	for i, l := range levels {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		for _, c := range l.Comments {
+			fmt.Fprintf(bw, "; %s\n", c)
+		}
+		if l.Title != "" {
+			fmt.Fprintf(bw, "%s: %s\n", titleKey, l.Title)
+		}
+		keys := make([]string, 0, len(l.Meta))
+		for k := range l.Meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(bw, "%s: %s\n", k, l.Meta[k])
+		}
+		if l.State.Edge != rules.EdgeWalled {
+			fmt.Fprintf(bw, "%s: %s\n", edgeKey, l.State.Edge)
+		}
+		if len(l.State.Portals) != 0 {
+			fmt.Fprintf(bw, "%s: %s\n", portalsKey, formatPairs(l.State.Portals))
+		}
+		if len(l.State.Links) != 0 {
+			fmt.Fprintf(bw, "%s: %s\n", linksKey, formatPairs(l.State.Links))
+		}
+		bw.WriteString(Format(l.State))
+	}
+	return bw.Flush()
+}
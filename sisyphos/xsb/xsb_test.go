@@ -0,0 +1,271 @@
+package xsb_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sisyphos.optimisticotter.me/sisyphos/rules"
+	"sisyphos.optimisticotter.me/sisyphos/xsb"
+)
+
+const pack = `; A small pack
+; by hand
+
+First steps
+#####
+#@$.#
+#####
+Author: somebody
+
+; the second one
+Title: Around the corner
+  ####
+###  #
+#@ $ #
+#  *.#
+######
+Edge: Toroidal
+`
+
+func TestParse(t *testing.T) {
+	levels, err := xsb.ParseString(pack)
+	require.NoError(t, err)
+	require.Len(t, levels, 2)
+
+	l := levels[0]
+	require.Equal(t, "First steps", l.Title)
+	require.Equal(t, []string{"A small pack", "by hand"}, l.Comments)
+	require.Equal(t, map[string]string{"Author": "somebody"}, l.Meta)
+	require.Equal(t, 5, l.State.Width)
+	require.Equal(t, 3, l.State.Height)
+	require.Equal(t, rules.Pos{X: 1, Y: 1}, l.State.Player)
+	require.Equal(t, []rules.Pos{{X: 2, Y: 1}}, l.State.Boulders)
+	require.Equal(t, []rules.Pos{{X: 3, Y: 1}}, l.State.Targets)
+	require.Equal(t, rules.Wall, l.State.CellAt(rules.Pos{X: 0, Y: 0}))
+	require.Equal(t, rules.EdgeWalled, l.State.Edge)
+
+	l = levels[1]
+	require.Equal(t, "Around the corner", l.Title)
+	require.Equal(t, []string{"the second one"}, l.Comments)
+	require.Equal(t, 6, l.State.Width)
+	require.Equal(t, 5, l.State.Height)
+	require.Equal(t, []rules.Pos{{X: 3, Y: 2}, {X: 3, Y: 3}}, l.State.Boulders)
+	require.Equal(t, []rules.Pos{{X: 3, Y: 3}, {X: 4, Y: 3}}, l.State.Targets)
+	require.Equal(t, rules.Floor, l.State.CellAt(rules.Pos{X: 0, Y: 0}))
+	require.Equal(t, rules.EdgeToroidal, l.State.Edge)
+}
+
+func TestParseWithoutWalls(t *testing.T) {
+	levels, err := xsb.ParseString("Wrap\nEdge: Toroidal\n----\n-@$.\n----\n")
+	require.NoError(t, err)
+	require.Len(t, levels, 1)
+	require.Equal(t, "Wrap", levels[0].Title)
+	require.Equal(t, 4, levels[0].State.Width)
+	require.Equal(t, 3, levels[0].State.Height)
+	require.Equal(t, rules.EdgeToroidal, levels[0].State.Edge)
+}
+
+func TestParseIce(t *testing.T) {
+	levels, err := xsb.ParseString("#####\n#@~$.#\n#~~ #\n#####\n")
+	require.NoError(t, err)
+	s := levels[0].State
+	require.Equal(t, rules.Ice, s.CellAt(rules.Pos{X: 2, Y: 1}))
+	require.Equal(t, rules.Ice, s.CellAt(rules.Pos{X: 1, Y: 2}))
+	require.Equal(t, rules.Floor, s.CellAt(rules.Pos{X: 3, Y: 2}))
+	require.Equal(t, "#####\n#@~$.#\n#~~ #\n#####\n", xsb.Format(s))
+}
+
+func TestParsePit(t *testing.T) {
+	// Titles and comments may consist of letters that are also cells.
+	levels, err := xsb.ParseString("Rook\n#######\n#@$o$.#\n#######\nDodo\n")
+	require.NoError(t, err)
+	require.Len(t, levels, 1)
+	require.Equal(t, "Rook", levels[0].Title)
+	require.Equal(t, []string{"Dodo"}, levels[0].Comments)
+	s := levels[0].State
+	require.Equal(t, rules.Pit, s.CellAt(rules.Pos{X: 3, Y: 1}))
+	require.Len(t, s.Boulders, 2)
+	require.Equal(t, "#######\n#@$o$.#\n#######\n", xsb.Format(s))
+}
+
+func TestParseSlope(t *testing.T) {
+	levels, err := xsb.ParseString("LURD\n######\n#@$L.#\n#URD #\n######\n")
+	require.NoError(t, err)
+	require.Equal(t, "LURD", levels[0].Title)
+	s := levels[0].State
+	require.Equal(t, rules.SlopeLeft, s.CellAt(rules.Pos{X: 3, Y: 1}))
+	require.Equal(t, rules.SlopeUp, s.CellAt(rules.Pos{X: 1, Y: 2}))
+	require.Equal(t, rules.SlopeRight, s.CellAt(rules.Pos{X: 2, Y: 2}))
+	require.Equal(t, rules.SlopeDown, s.CellAt(rules.Pos{X: 3, Y: 2}))
+	require.Equal(t, "######\n#@$L.#\n#URD #\n######\n", xsb.Format(s))
+}
+
+func TestParseKey(t *testing.T) {
+	levels, err := xsb.ParseString("dk\n#######\n#@k$d.#\n#######\n")
+	require.NoError(t, err)
+	require.Equal(t, "dk", levels[0].Title)
+	s := levels[0].State
+	require.Equal(t, rules.Key, s.CellAt(rules.Pos{X: 2, Y: 1}))
+	require.Equal(t, rules.Door, s.CellAt(rules.Pos{X: 4, Y: 1}))
+	require.Equal(t, "#######\n#@k$d.#\n#######\n", xsb.Format(s))
+}
+
+func TestParseOneWay(t *testing.T) {
+	levels, err := xsb.ParseString("#######\n#@>$.<#\n#^ v  #\n#######\n")
+	require.NoError(t, err)
+	s := levels[0].State
+	require.Equal(t, rules.OneWayRight, s.CellAt(rules.Pos{X: 2, Y: 1}))
+	require.Equal(t, rules.OneWayLeft, s.CellAt(rules.Pos{X: 5, Y: 1}))
+	require.Equal(t, rules.OneWayUp, s.CellAt(rules.Pos{X: 1, Y: 2}))
+	require.Equal(t, rules.OneWayDown, s.CellAt(rules.Pos{X: 3, Y: 2}))
+	require.Equal(t, "#######\n#@>$.<#\n#^ v  #\n#######\n", xsb.Format(s))
+}
+
+func TestParsePortals(t *testing.T) {
+	levels, err := xsb.ParseString("Portals: 3,1 5,1; 1,2 6,2\n########\n#@$  ..#\n#     $#\n########\n")
+	require.NoError(t, err)
+	require.Equal(t, [][2]rules.Pos{
+		{{X: 3, Y: 1}, {X: 5, Y: 1}},
+		{{X: 1, Y: 2}, {X: 6, Y: 2}},
+	}, levels[0].State.Portals)
+	require.Empty(t, levels[0].Meta)
+
+	var buf bytes.Buffer
+	require.NoError(t, xsb.Write(&buf, levels))
+	again, err := xsb.Parse(&buf)
+	require.NoError(t, err)
+	require.Equal(t, levels, again)
+
+	_, err = xsb.ParseString("Portals: 3,1\n#####\n#@$.#\n#####\n")
+	var syntaxErr *xsb.SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+}
+
+func TestParseLinks(t *testing.T) {
+	levels, err := xsb.ParseString("Links: 3,1 3,2\n======\n#####\n#@$=#\n#. |#\n#####\n")
+	require.NoError(t, err)
+	require.Equal(t, "======", levels[0].Title)
+	s := levels[0].State
+	require.Equal(t, rules.Plate, s.CellAt(rules.Pos{X: 3, Y: 1}))
+	require.Equal(t, rules.Gate, s.CellAt(rules.Pos{X: 3, Y: 2}))
+	require.Equal(t, [][2]rules.Pos{{{X: 3, Y: 1}, {X: 3, Y: 2}}}, s.Links)
+
+	var buf bytes.Buffer
+	require.NoError(t, xsb.Write(&buf, levels))
+	again, err := xsb.Parse(&buf)
+	require.NoError(t, err)
+	require.Equal(t, levels, again)
+
+	_, err = xsb.ParseString("Links: 1,1 1,1\n#####\n#@$.#\n#####\n")
+	var syntaxErr *xsb.SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+}
+
+func TestRoundTrip(t *testing.T) {
+	levels, err := xsb.ParseString(pack)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, xsb.Write(&buf, levels))
+	again, err := xsb.Parse(&buf)
+	require.NoError(t, err)
+	require.Equal(t, levels, again)
+}
+
+func TestFormat(t *testing.T) {
+	s := rules.New(4, 2)
+	s.SetCell(rules.Pos{X: 0, Y: 0}, rules.Wall)
+	s.Player = rules.Pos{X: 1, Y: 0}
+	s.Targets = []rules.Pos{{X: 1, Y: 0}, {X: 2, Y: 1}}
+	s.Boulders = []rules.Pos{{X: 2, Y: 1}, {X: 0, Y: 1}}
+	require.Equal(t, "#+\n$ *\n", xsb.Format(s))
+
+	s = rules.New(3, 2)
+	s.Player = rules.Pos{X: 1, Y: 1}
+	require.Equal(t, "---\n @\n", xsb.Format(s))
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Input string
+		Line  int
+		Col   int
+	}{
+		{
+			Name:  "invalid character",
+			Input: "Title: x\n#####\n#@$x#\n#####\n",
+			Line:  3,
+			Col:   4,
+		},
+		{
+			Name:  "no player",
+			Input: "\n\n#####\n# $.#\n#####\n",
+			Line:  3,
+			Col:   1,
+		},
+		{
+			Name:  "two players",
+			Input: "#####\n#@$.#\n#@  #\n#####\n",
+			Line:  3,
+			Col:   2,
+		},
+		{
+			Name:  "missing boulder",
+			Input: "######\n#@$..#\n######\n",
+			Line:  1,
+			Col:   1,
+		},
+		{
+			Name:  "unknown edge mode",
+			Input: "####\n#@*#\n####\nEdge: spherical\n",
+			Line:  4,
+			Col:   7,
+		},
+		{
+			Name:  "portal outside of the board",
+			Input: "Portals: 2,1 40,40\n#####\n#@$.#\n#####\n",
+			Line:  2,
+			Col:   1,
+		},
+		{
+			Name:  "portal on a wall",
+			Input: "Portals: 2,1 0,0\n#####\n#@$.#\n#####\n",
+			Line:  2,
+			Col:   1,
+		},
+		{
+			Name:  "portal on a wall after the board",
+			Input: "#####\n#@$.#\n#####\nPortals: 2,1 4,1\n",
+			Line:  4,
+			Col:   10,
+		},
+		{
+			Name:  "link without a plate",
+			Input: "Links: 0,0 1,1\n#####\n#@$.#\n#####\n",
+			Line:  2,
+			Col:   1,
+		},
+		{
+			Name:  "link without a gate",
+			Input: "Links: 3,1 3,2\n#####\n#@$=#\n#.  #\n#####\n",
+			Line:  2,
+			Col:   1,
+		},
+		{
+			Name:  "link outside of the board after the board",
+			Input: "#####\n#@$=#\n#. |#\n#####\nLinks: 3,1 9,9\n",
+			Line:  5,
+			Col:   8,
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := xsb.ParseString(test.Input)
+			var syntaxErr *xsb.SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			require.Equal(t, test.Line, syntaxErr.Line)
+			require.Equal(t, test.Col, syntaxErr.Col)
+		})
+	}
+}